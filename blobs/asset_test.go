@@ -34,6 +34,7 @@ func TestCanOtherAssets(t *testing.T) {
 	wont_be.Panic(func() { blobs.MustAsset("assets/templates.yaml") })
 	wont_be.Panic(func() { blobs.MustAsset("assets/settings.yaml") })
 	wont_be.Panic(func() { blobs.MustAsset("assets/speedtest.yaml") })
+	wont_be.Panic(func() { blobs.MustAsset("assets/diagnostics_schema.json") })
 
 	wont_be.Panic(func() { blobs.MustAsset("assets/man/LICENSE.txt") })
 	wont_be.Panic(func() { blobs.MustAsset("assets/man/tutorial.txt") })
@@ -49,6 +50,14 @@ func TestCanOtherAssets(t *testing.T) {
 	wont_be.Panic(func() { blobs.MustMicromamba() })
 }
 
+func TestCanGetEmbeddedMicromambaDigest(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	digest, err := blobs.MicromambaDigest()
+	must_be.Nil(err)
+	must_be.Equal(64, len(digest))
+}
+
 func TestCanGetTemplateNamesThruOperations(t *testing.T) {
 	must_be, wont_be := hamlet.Specifications(t)
 
@@ -1,7 +1,12 @@
 package blobs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"embed"
+	"fmt"
+	"io"
 	"strings"
 )
 
@@ -13,7 +18,7 @@ const (
 
 //go:embed assets/*.yaml docs/*.md
 //go:embed assets/*.zip assets/man/*.txt
-//go:embed assets/*.txt
+//go:embed assets/*.txt assets/*.json
 //go:embed assets/*.py
 var content embed.FS
 
@@ -44,3 +49,18 @@ func MicromambaVersion() string {
 	}
 	return strings.TrimSpace(string(body))
 }
+
+// MicromambaDigest is SHA256 of micromamba binary embedded in this rcc
+// build, which is what extracted binary should always match.
+func MicromambaDigest() (string, error) {
+	source, err := gzip.NewReader(bytes.NewReader(MustMicromamba()))
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.New()
+	_, err = io.Copy(digest, source)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02x", digest.Sum(nil)), nil
+}
@@ -1,13 +1,19 @@
 package cloud
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/robocorp/rcc/common"
@@ -33,10 +39,13 @@ type Request struct {
 }
 
 type Response struct {
-	Status  int
-	Err     error
-	Body    []byte
-	Elapsed common.Duration
+	Status   int
+	Err      error
+	Body     []byte
+	Proto    string
+	Header   http.Header
+	Elapsed  common.Duration
+	Attempts int
 }
 
 type Client interface {
@@ -44,6 +53,7 @@ type Client interface {
 	NewRequest(string) *Request
 	Head(request *Request) *Response
 	Get(request *Request) *Response
+	GetWithRetry(request *Request, attempts int) *Response
 	Post(request *Request) *Response
 	Put(request *Request) *Response
 	Delete(request *Request) *Response
@@ -52,6 +62,10 @@ type Client interface {
 	WithTracing() Client
 }
 
+var (
+	retryBackoff = 250 * time.Millisecond
+)
+
 func EnsureHttps(endpoint string) (string, error) {
 	nice := strings.TrimRight(strings.TrimSpace(endpoint), "/")
 	parsed, err := url.Parse(nice)
@@ -70,7 +84,7 @@ func EnsureHttps(endpoint string) (string, error) {
 func NewUnsafeClient(endpoint string) (Client, error) {
 	return &internalClient{
 		endpoint: endpoint,
-		client:   &http.Client{Transport: settings.Global.ConfiguredHttpTransport()},
+		client:   &http.Client{Transport: settings.Global.SharedHttpTransport()},
 		tracing:  false,
 	}, nil
 }
@@ -82,7 +96,7 @@ func NewClient(endpoint string) (Client, error) {
 	}
 	return &internalClient{
 		endpoint: https,
-		client:   &http.Client{Transport: settings.Global.ConfiguredHttpTransport()},
+		client:   &http.Client{Transport: settings.Global.SharedHttpTransport()},
 		tracing:  false,
 	}, nil
 }
@@ -91,7 +105,7 @@ func (it *internalClient) WithTimeout(timeout time.Duration) Client {
 	return &internalClient{
 		endpoint: it.endpoint,
 		client: &http.Client{
-			Transport: settings.Global.ConfiguredHttpTransport(),
+			Transport: settings.Global.SharedHttpTransport(),
 			Timeout:   timeout,
 		},
 		tracing: it.tracing,
@@ -114,9 +128,42 @@ func (it *internalClient) Endpoint() string {
 	return it.endpoint
 }
 
+func (it *Request) hasHeader(name string) bool {
+	for key := range it.Headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// decoded undoes gzip or deflate content encoding of response body, unless
+// transport already did it (which happens only when it asked gzip itself)
+func decoded(response *http.Response) (io.Reader, error) {
+	if response.Uncompressed || response.Request.Method == "HEAD" || response.ContentLength == 0 {
+		return response.Body, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(response.Body)
+	case "deflate":
+		// "deflate" should be zlib wrapped, but some servers send raw deflate
+		source := bufio.NewReader(response.Body)
+		head, err := source.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			return zlib.NewReader(source)
+		}
+		return flate.NewReader(source), nil
+	}
+	return response.Body, nil
+}
+
 func (it *internalClient) does(method string, request *Request) *Response {
 	stopwatch := common.Stopwatch("stopwatch")
-	response := new(Response)
+	response := &Response{Attempts: 1}
 	url := it.Endpoint() + request.Url
 	common.Trace("Doing %s %s", method, url)
 	defer func() {
@@ -140,6 +187,9 @@ func (it *internalClient) does(method string, request *Request) *Response {
 	for name, value := range request.Headers {
 		httpRequest.Header.Add(name, value)
 	}
+	if !request.hasHeader("Accept-Encoding") && !request.hasHeader("Range") {
+		httpRequest.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
 	httpResponse, err := it.client.Do(httpRequest)
 	if err != nil {
 		common.Error("http.Do", err)
@@ -156,10 +206,24 @@ func (it *internalClient) does(method string, request *Request) *Response {
 		}
 	}
 	response.Status = httpResponse.StatusCode
+	response.Proto = httpResponse.Proto
+	response.Header = httpResponse.Header
+	body, err := decoded(httpResponse)
+	if err != nil {
+		response.Err = fmt.Errorf("Could not decode %q content, reason: %v", httpResponse.Header.Get("Content-Encoding"), err)
+		return response
+	}
+	if body != httpResponse.Body {
+		response.Header.Del("Content-Encoding")
+		response.Header.Del("Content-Length")
+		if closer, ok := body.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
 	if request.Stream != nil {
-		io.Copy(request.Stream, httpResponse.Body)
+		_, response.Err = io.Copy(request.Stream, body)
 	} else {
-		response.Body, response.Err = io.ReadAll(httpResponse.Body)
+		response.Body, response.Err = io.ReadAll(body)
 	}
 	if common.DebugFlag() {
 		body := "ignore"
@@ -186,6 +250,33 @@ func (it *internalClient) Get(request *Request) *Response {
 	return it.does("GET", request)
 }
 
+func retryable(response *Response) bool {
+	if response.Status > 499 && response.Status < 600 {
+		return true
+	}
+	if response.Status != 9002 || response.Err == nil {
+		return false
+	}
+	return errors.Is(response.Err, syscall.ECONNRESET) || strings.Contains(response.Err.Error(), "connection reset")
+}
+
+func (it *internalClient) GetWithRetry(request *Request, attempts int) *Response {
+	if attempts < 1 || request.Stream != nil {
+		attempts = 1
+	}
+	delay := retryBackoff
+	for attempt := 1; ; attempt++ {
+		response := it.does("GET", request)
+		response.Attempts = attempt
+		if attempt >= attempts || !retryable(response) {
+			return response
+		}
+		common.Debug("GET %s%s attempt %d/%d failed with %d (%v), retrying in %s.", it.endpoint, request.Url, attempt, attempts, response.Status, response.Err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
 func (it *internalClient) Post(request *Request) *Response {
 	return it.does("POST", request)
 }
@@ -209,7 +300,7 @@ func Download(url, filename string) error {
 		}
 	}
 
-	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	client := &http.Client{Transport: settings.Global.SharedHttpTransport()}
 	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
@@ -1,6 +1,13 @@
 package cloud_test
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -46,3 +53,111 @@ func TestCanEnsureHttps(t *testing.T) {
 	must_be.Nil(err)
 	must_be.Equal(special, output)
 }
+
+func TestGetWithRetryRetriesServerErrors(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	sut, err := cloud.NewClient(server.URL)
+	must_be.Nil(err)
+	wont_be.Nil(sut)
+
+	response := sut.GetWithRetry(sut.NewRequest("/"), 3)
+	must_be.Equal(200, response.Status)
+	must_be.Equal(3, response.Attempts)
+	must_be.Equal("ok", string(response.Body))
+}
+
+func TestGetWithRetryDoesNotRetryClientErrors(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sut, err := cloud.NewClient(server.URL)
+	must_be.Nil(err)
+	wont_be.Nil(sut)
+
+	response := sut.GetWithRetry(sut.NewRequest("/"), 3)
+	must_be.Equal(404, response.Status)
+	must_be.Equal(1, response.Attempts)
+	must_be.Equal(1, calls)
+}
+
+func TestClientDecodesCompressedContent(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	content := strings.Repeat("Used to testing connections\n", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		buffer := &bytes.Buffer{}
+		var sink io.WriteCloser
+		switch encoding {
+		case "gzip":
+			sink = gzip.NewWriter(buffer)
+		case "truncated":
+			sink = gzip.NewWriter(buffer)
+			encoding = "gzip"
+		case "deflate":
+			sink = zlib.NewWriter(buffer)
+		case "raw":
+			sink, _ = flate.NewWriter(buffer, flate.BestSpeed)
+			encoding = "deflate"
+		default:
+			w.Write([]byte(content))
+			return
+		}
+		must_be.True(strings.Contains(r.Header.Get("Accept-Encoding"), "gzip"))
+		sink.Write([]byte(content))
+		sink.Close()
+		body := buffer.Bytes()
+		if r.URL.Path == "/truncated" {
+			body = body[:len(body)/2]
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	sut, err := cloud.NewClient(server.URL)
+	must_be.Nil(err)
+	wont_be.Nil(sut)
+
+	for _, path := range []string{"/gzip", "/deflate", "/raw", "/plain"} {
+		response := sut.Get(sut.NewRequest(path))
+		must_be.Nil(response.Err)
+		must_be.Equal(200, response.Status)
+		must_be.Equal(content, string(response.Body))
+		must_be.Equal("", response.Header.Get("Content-Encoding"))
+	}
+
+	stream := &bytes.Buffer{}
+	request := sut.NewRequest("/gzip")
+	request.Stream = stream
+	response := sut.Get(request)
+	must_be.Nil(response.Err)
+	must_be.Equal(content, stream.String())
+
+	request = sut.NewRequest("/truncated")
+	request.Stream = &bytes.Buffer{}
+	response = sut.Get(request)
+	wont_be.Nil(response.Err)
+
+	head := sut.Head(sut.NewRequest("/gzip"))
+	must_be.Nil(head.Err)
+	must_be.Equal(200, head.Status)
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/operations"
 	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/settings"
 
 	"github.com/spf13/cobra"
 )
@@ -12,32 +16,149 @@ var (
 	fileOption      string
 	robotOption     string
 	quickFilterFlag bool
+	dnsTtlFlag      bool
+	ioBenchmarkFlag bool
+	benchmarkFlag   bool
+	saveFlag        bool
+	uploadFlag      bool
+	payloadFlag     bool
+	omitDetails     []string
+	formatOption    string
+	categoryFilter  []string
+	exportCerts     string
+	tlsHosts        []string
+	appendFlag      bool
+	offlineFlag     bool
+	anonymizeFlag   bool
+	streamFlag      bool
+	syslogFlag      bool
+	servePort       int
+	serveAddress    string
+	serveTTL        time.Duration
+	connectTo       string
+	sniOption       string
+	failuresOnly    bool
+	failureLevel    string
+	jsonFileOption  string
+	onlyChecks      []string
+	skipChecks      []string
+	dnsServer       string
 )
 
 var diagnosticsCmd = &cobra.Command{
 	Use:     "diagnostics",
 	Aliases: []string{"diagnostic", "diag"},
 	Short:   "Run system diagnostics to help resolve rcc issues.",
-	Long:    "Run system diagnostics to help resolve rcc issues.",
+	Long: `Run system diagnostics to help resolve rcc issues.
+
+Exit code is 3 if any check is fatal, 2 if any check failed, and 1 if
+any check has warning and --strict flag is given. Otherwise it is 0.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if common.DebugFlag() {
 			defer common.Stopwatch("Diagnostic run lasted").Report()
 		}
-		_, err := operations.ProduceDiagnostics(fileOption, robotOption, jsonFlag, productionFlag, quickFilterFlag || common.WarrantyVoided())
+		options := &operations.DiagnosticsOptions{
+			Quick:       quickFilterFlag || common.WarrantyVoided(),
+			DnsTTL:      dnsTtlFlag,
+			IoBenchmark: ioBenchmarkFlag,
+			Benchmark:   benchmarkFlag,
+			UploadCheck: uploadFlag,
+			Payload:     payloadFlag,
+			DryRun:      dryFlag,
+			Save:        saveFlag,
+			OmitDetails: omitDetails,
+			Anonymize:   anonymizeFlag,
+			Stream:      streamFlag,
+			Syslog:      syslogFlag,
+			JsonFile:    jsonFileOption,
+			Only:        onlyChecks,
+			Skip:        skipChecks,
+			DnsServer:   dnsServer,
+			Categories:  categoryFilter,
+			TlsHosts:    tlsHosts,
+			ConnectTo:   connectTo,
+			SNI:         sniOption,
+			Append:      appendFlag,
+			Offline:     offlineFlag,
+		}
+		if servePort > 0 {
+			err := operations.ServeDiagnostics(serveAddress, servePort, serveTTL, options)
+			if err != nil {
+				pretty.Exit(1, "Error: %v", err)
+			}
+			return
+		}
+		if failuresOnly {
+			options.FailuresAt = failureLevel
+		}
+		format := formatOption
+		if jsonFlag {
+			format = "json"
+		}
+		result, err := operations.ProduceDiagnosticsFormat(fileOption, robotOption, format, productionFlag, options)
 		if err != nil {
 			pretty.Exit(1, "Error: %v", err)
 		}
+		if len(exportCerts) > 0 && !dryFlag {
+			exportCertificateChains(exportCerts)
+		}
+		code := result.ExitCode(common.StrictFlag)
+		if code > 0 {
+			fatal, fail, warning, _ := result.Counts()
+			pretty.Exit(code, "Diagnostics found %d fatal, %d failed, and %d warning checks.", fatal, fail, warning)
+		}
 		pretty.Ok()
 	},
 }
 
+func exportCertificateChains(filename string) {
+	sink, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	pretty.Guard(err == nil, 1, "Could not create %q, reason: %v", filename, err)
+	defer sink.Close()
+	for _, host := range settings.Global.Hostnames() {
+		err := operations.ExportCertificateChain(host, sink)
+		if err != nil {
+			pretty.Warning("Certificate chain of %q was not exported, reason: %v", host, err)
+		}
+	}
+	common.Log("Observed certificate chains were exported into %q.", filename)
+}
+
 func init() {
 	configureCmd.AddCommand(diagnosticsCmd)
 	rootCmd.AddCommand(diagnosticsCmd)
 
 	diagnosticsCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output in JSON format.")
+	diagnosticsCmd.Flags().StringVarP(&formatOption, "format", "", "text", "Output format, one of: text, json, yaml, html. Flag --json overrides this.")
 	diagnosticsCmd.Flags().BoolVarP(&quickFilterFlag, "quick", "q", false, "Only run quick diagnostics.")
 	diagnosticsCmd.Flags().StringVarP(&fileOption, "file", "f", "", "Save output into a file.")
 	diagnosticsCmd.Flags().StringVarP(&robotOption, "robot", "r", "", "Full path to 'robot.yaml' configuration file. [optional]")
 	diagnosticsCmd.Flags().BoolVarP(&productionFlag, "production", "p", false, "Checks for production level robots. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&offlineFlag, "offline", "", false, "Skip all network checks, for air-gapped environments. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&dnsServer, "dns-server", "", "", "Resolve hosts in DNS lookup checks using this DNS server (like 8.8.8.8) instead of system resolver. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&dnsTtlFlag, "dns-ttl", "", false, "Also check DNS TTL of downloads host for signs of intercepting resolver. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&benchmarkFlag, "benchmark", "", false, "Also time creation of throwaway environment (resolve, download, link phases). Slow and needs network. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&ioBenchmarkFlag, "io-benchmark", "", false, "Also benchmark write/read/hash and small file speed of hololib storage. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&payloadFlag, "large-payload", "", false, "Also download large payload (diagnostics/payload-megabytes) to detect MTU, truncation, and throughput problems. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&uploadFlag, "upload-check", "", false, "Also check that outbound POST/PUT traffic to diagnostics/upload-url (from settings.yaml) is permitted, not just downloads. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&dryFlag, "dryrun", "d", false, "Don't run any checks, just list which checks would run. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&appendFlag, "append", "", false, "Append into --file instead of overwriting it. With JSON, output is one compact line per run (JSONL). [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&saveFlag, "save", "", false, "Save JSON output into a new temporary file and print its path. Ignored if --file is given. [optional]")
+	diagnosticsCmd.Flags().StringSliceVarP(&onlyChecks, "only", "", []string{}, "Only run checks selected by name, name prefix (like tls), kind (OS, RPA, network) or category code, comma separated or repeated. [optional]")
+	diagnosticsCmd.Flags().StringSliceVarP(&skipChecks, "skip", "", []string{}, "Skip checks selected by name, name prefix, kind or category code, comma separated or repeated. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&jsonFileOption, "json-file", "", "", "Also save JSON output into this file, in addition to normal output, from same run. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&streamFlag, "stream", "", false, "Write each check as JSON line as soon as it finishes, and details as last line. Implies JSON format. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&syslogFlag, "syslog", "", false, "Write each check as syslog message (severity from check status) instead of file or stdout. On Windows, lines go to stderr. [optional]")
+	diagnosticsCmd.Flags().IntVarP(&servePort, "serve", "", 0, "Serve diagnostics as JSON on this HTTP port until interrupted; status is 200 when no check fails, 503 otherwise. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&serveAddress, "serve-address", "", "127.0.0.1", "Address to bind --serve server to. Results contain user and host details, so use 0.0.0.0 (all interfaces) with care. [optional]")
+	diagnosticsCmd.Flags().DurationVarP(&serveTTL, "serve-ttl", "", 30*time.Second, "How long --serve caches diagnostics results between probes. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&anonymizeFlag, "anonymize", "", false, "Replace installation, controller and user identities, user names, host name and home paths with stable hashed placeholders, for sharing output publicly. [optional]")
+	diagnosticsCmd.Flags().StringSliceVarP(&categoryFilter, "category", "", []string{}, "Only show checks of these types (OS, RPA, network) or category codes, comma separated or repeated. [optional]")
+	diagnosticsCmd.Flags().BoolVarP(&failuresOnly, "failures-only", "", false, "Only show checks at or above --failure-threshold status, but keep details. Checks is empty list, when all is well. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&failureLevel, "failure-threshold", "", "warning", "Lowest status shown with --failures-only, one of: warning, fail, fatal. [optional]")
+	diagnosticsCmd.Flags().StringSliceVarP(&tlsHosts, "tls-host", "", []string{}, "Only run TLS checks against these hostnames or host:port pairs, comma separated or repeated. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&connectTo, "connect-to", "", "", "Make TLS checks connect to this address (ip or ip:port) instead of resolving host, bypassing proxy. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&sniOption, "sni", "", "", "Present this SNI hostname in TLS checks; also used as --tls-host if none is given. [optional]")
+	diagnosticsCmd.Flags().StringVarP(&exportCerts, "export-certs", "", "", "Also export observed TLS certificate chains of diagnostics hosts into this PEM file. [optional]")
+	diagnosticsCmd.Flags().StringSliceVarP(&omitDetails, "omit", "", []string{}, "Details keys to leave out from output, comma separated or repeated. [optional]")
 }
@@ -1,21 +1,186 @@
 package common
 
 const (
-	CategoryUndefined           = 0
-	CategoryLongPath            = 1010
-	CategoryLockFile            = 1020
-	CategoryLockPid             = 1021
-	CategoryPathCheck           = 1030
-	CategoryEnvVarCheck         = 1040
-	CategoryHolotreeShared      = 2010
-	CategoryRobocorpHome        = 3010
-	CategoryRobocorpHomeMembers = 3020
-	CategoryNetworkDNS          = 4010
-	CategoryNetworkLink         = 4020
-	CategoryNetworkHEAD         = 4030
-	CategoryNetworkCanary       = 4040
-	CategoryNetworkTLSVersion   = 4050
-	CategoryNetworkTLSVerify    = 4060
-	CategoryNetworkTLSChain     = 4070
-	CategoryEnvironmentCache    = 5010
+	CategoryUndefined            = 0
+	CategoryLongPath             = 1010
+	CategoryLockFile             = 1020
+	CategoryLockPid              = 1021
+	CategoryLockStale            = 1022
+	CategoryPathCheck            = 1030
+	CategoryEnvVarCheck          = 1040
+	CategoryEnvPollution         = 1041
+	CategoryHomeVariable         = 1050
+	CategoryMemorySwap           = 1060
+	CategoryEntropy              = 1061
+	CategoryCertificateStore     = 1070
+	CategoryReadiness            = 1080
+	CategoryRccOnPath            = 1090
+	CategoryRccLocation          = 1091
+	CategoryTempDirectory        = 1100
+	CategoryLocalListener        = 1110
+	CategoryCpuAffinity          = 1120
+	CategoryClockSkew            = 1130
+	CategoryTimeZone             = 1131
+	CategoryAntivirus            = 1140
+	CategoryWsl                  = 1150
+	CategoryHolotreeShared       = 2010
+	CategoryHolotreeSharedMode   = 2020
+	CategoryHolotreeSpaces       = 2030
+	CategoryRobocorpHome         = 3010
+	CategoryRobocorpHomeMembers  = 3020
+	CategoryConfigPermissions    = 3030
+	CategoryRobocorpHomeNames    = 3040
+	CategoryCaseSensitivity      = 3050
+	CategoryDiskSpace            = 3060
+	CategoryRobocorpHomeNetwork  = 3070
+	CategoryMicromambaIntegrity  = 3080
+	CategoryRobocorpHomeExec     = 3090
+	CategoryNetworkOffline       = 4000
+	CategoryNetworkDNS           = 4010
+	CategoryNetworkDNSTTL        = 4011
+	CategoryNetworkDNSFamily     = 4012
+	CategoryNetworkHostsFile     = 4013
+	CategoryNetworkLink          = 4020
+	CategoryNetworkHEAD          = 4030
+	CategoryNetworkReachability  = 4031
+	CategoryNetworkCanary        = 4040
+	CategoryNetworkLargePayload  = 4041
+	CategoryNetworkCaptivePortal = 4042
+	CategoryNetworkCanaryDNS     = 4043
+	CategoryNetworkCanaryTCP     = 4044
+	CategoryNetworkCanaryTLS     = 4045
+	CategoryNetworkCanaryHTTP    = 4046
+	CategoryNetworkThroughput    = 4047
+	CategoryNetworkCanaryIPv6    = 4048
+	CategoryNetworkTLSVersion    = 4050
+	CategoryNetworkTLSMinimum    = 4051
+	CategoryNetworkTLSCipher     = 4052
+	CategoryNetworkTLSVerify     = 4060
+	CategoryNetworkTLSExpiry     = 4061
+	CategoryNetworkOCSP          = 4062
+	CategoryNetworkTLSClientAuth = 4063
+	CategoryNetworkTLSPin        = 4064
+	CategoryNetworkTLSChain      = 4070
+	CategoryNetworkTLSRoots      = 4071
+	CategoryNetworkTLSIssuers    = 4072
+	CategoryNetworkProxyRouting  = 4080
+	CategoryNetworkProxyTunnel   = 4081
+	CategoryNetworkProtocol      = 4090
+	CategoryNetworkALPN          = 4091
+	CategoryNetworkTelemetry     = 4100
+	CategoryNetworkUpload        = 4110
+	CategoryNetworkCaSources     = 4120
+	CategoryNetworkRange         = 4130
+	CategoryEnvironmentCache     = 5010
+	CategoryHolotreeBenchmark    = 5020
+	CategoryEnvironmentBenchmark = 5021
+	CategoryMicromambaVersion    = 5030
+	CategoryVirtualPackages      = 5040
+	CategoryBuildTools           = 5050
 )
+
+var (
+	statusCodes = map[string]uint64{
+		StatusOk:      0,
+		StatusWarning: 1,
+		StatusFail:    2,
+		StatusFatal:   3,
+	}
+)
+
+// DiagnosticCode is generic code of category and status, for checks made
+// through Diagnoser, which have no own outcome in catalog of codes.go.
+// Categories above are part of codes, so never renumber them.
+func DiagnosticCode(category uint64, status string) uint64 {
+	offset, ok := statusCodes[status]
+	if !ok {
+		offset = 9
+	}
+	return category*10 + offset
+}
+
+var (
+	// remediations are next steps to take, when check of category is not ok
+	remediations = map[uint64]string{
+		CategoryLongPath:             "Enable long path support in Windows (LongPathsEnabled registry setting) and restart.",
+		CategoryLockFile:             "Make sure lock files under ROBOCORP_HOME are writable by current user.",
+		CategoryLockPid:              "Wait for other rcc processes to finish, or stop them, before running again.",
+		CategoryLockStale:            "Remove stale lock files left behind by terminated rcc processes.",
+		CategoryPathCheck:            "Remove conflicting Python or conda entries from PATH before running rcc.",
+		CategoryEnvVarCheck:          "Unset environment variables that redirect Python or conda (like PYTHONPATH or CONDA_PREFIX).",
+		CategoryEnvPollution:         "Run rcc from clean shell, without activated virtual or conda environments.",
+		CategoryHomeVariable:         "Set home directory variables to point to one existing, writable directory.",
+		CategoryMemorySwap:           "Free memory or add swap space, so that environment builds do not run out of memory.",
+		CategoryEntropy:              "Install entropy daemon (like haveged or rng-tools) so that TLS and key generation do not stall.",
+		CategoryCertificateStore:     "Install missing corporate root certificates into system certificate store.",
+		CategoryReadiness:            "Fix blocking checks listed above before running robots.",
+		CategoryRccOnPath:            "Put directory containing rcc on PATH, and remove other rcc versions from it.",
+		CategoryRccLocation:          "Move rcc executable into directory that is writable only by its owner.",
+		CategoryTempDirectory:        "Point TMP/TEMP/TMPDIR to existing directory with free space and write and execute rights.",
+		CategoryLocalListener:        "Allow local loopback connections in firewall, and check that no other process holds needed ports.",
+		CategoryCpuAffinity:          "Allow rcc to use more CPU cores, by removing CPU affinity or container CPU limits.",
+		CategoryClockSkew:            "Synchronize system clock with time server (NTP), since TLS and signatures depend on it.",
+		CategoryTimeZone:             "Install time zone database (tzdata) or set TZ variable to valid zone.",
+		CategoryAntivirus:            "Exclude ROBOCORP_HOME from antivirus real-time scanning.",
+		CategoryWsl:                  "Use ROBOCORP_HOME inside Linux filesystem of WSL, not under /mnt mounted Windows drives.",
+		CategoryHolotreeShared:       "Run 'rcc holotree shared --enable' with administrator rights, or stop using shared holotree.",
+		CategoryHolotreeSharedMode:   "Fix permissions of shared holotree, by running 'rcc holotree shared --enable' again with administrator rights.",
+		CategoryHolotreeSpaces:       "Remove unused holotree spaces with 'rcc holotree delete' or 'rcc configuration cleanup'.",
+		CategoryRobocorpHome:         "Set ROBOCORP_HOME to short, local path which current user can write to.",
+		CategoryRobocorpHomeMembers:  "Fix ownership and permissions of files and directories under ROBOCORP_HOME.",
+		CategoryConfigPermissions:    "Restrict settings and credential files so that only current user can read them.",
+		CategoryRobocorpHomeNames:    "Use ROBOCORP_HOME path without spaces or non-ASCII characters.",
+		CategoryCaseSensitivity:      "Use ROBOCORP_HOME on filesystem with consistent case sensitivity handling.",
+		CategoryDiskSpace:            "Free disk space on ROBOCORP_HOME volume, for example with 'rcc configuration cleanup'.",
+		CategoryRobocorpHomeNetwork:  "Move ROBOCORP_HOME from network drive to local disk.",
+		CategoryMicromambaIntegrity:  "Remove broken micromamba binary from ROBOCORP_HOME, and let rcc download it again.",
+		CategoryRobocorpHomeExec:     "Move ROBOCORP_HOME to filesystem which allows executing programs (not mounted noexec).",
+		CategoryNetworkOffline:       "Connect to network, or use --offline flag for air-gapped diagnostics.",
+		CategoryNetworkDNS:           "Check DNS resolver configuration, and that needed hostnames are allowed by DNS filtering.",
+		CategoryNetworkDNSTTL:        "Ask network administrators if DNS responses are intercepted or rewritten by middlebox.",
+		CategoryNetworkDNSFamily:     "Make sure DNS returns usable addresses for IP family (IPv4/IPv6) your network supports.",
+		CategoryNetworkHostsFile:     "Remove overrides of needed hostnames from system hosts file.",
+		CategoryNetworkLink:          "Check network interface, cabling or Wi-Fi, and default route of this machine.",
+		CategoryNetworkHEAD:          "Allow needed hosts in firewall and proxy allowlists, and configure proxy in settings.yaml if needed.",
+		CategoryNetworkReachability:  "Allow outgoing HTTPS (port 443) connections to needed hosts in firewall.",
+		CategoryNetworkCanary:        "Allow downloads from needed hosts in firewall, proxy and content filtering.",
+		CategoryNetworkLargePayload:  "Ask network administrators to raise download size limits of proxy or content filter.",
+		CategoryNetworkCaptivePortal: "Log in to captive portal (hotel, guest or public Wi-Fi) using browser, then try again.",
+		CategoryNetworkCanaryDNS:     "Fix DNS resolution of download hosts (see DNS checks).",
+		CategoryNetworkCanaryTCP:     "Allow outgoing TCP connections to download hosts in firewall.",
+		CategoryNetworkCanaryTLS:     "Fix TLS connection to download hosts (see TLS checks), often by adding corporate root certificate.",
+		CategoryNetworkCanaryHTTP:    "Check that proxy or content filter does not block or rewrite downloads.",
+		CategoryNetworkThroughput:    "Expect slow environment builds, or ask network administrators about bandwidth limits.",
+		CategoryNetworkCanaryIPv6:    "Fix IPv6 routing or disable IPv6, so that connections do not fail over slowly.",
+		CategoryNetworkTLSVersion:    "Allow TLS 1.2 or newer in proxy and security appliances.",
+		CategoryNetworkTLSMinimum:    "Upgrade servers and middleboxes to TLS 1.2 or newer.",
+		CategoryNetworkTLSCipher:     "Allow modern TLS cipher suites in proxy and security appliances.",
+		CategoryNetworkTLSVerify:     "Add corporate root certificate into system store or settings.yaml 'certificates' section.",
+		CategoryNetworkTLSExpiry:     "Ask server or proxy administrators to renew expiring certificate.",
+		CategoryNetworkOCSP:          "Ask server administrators to check certificate revocation status and stapling.",
+		CategoryNetworkTLSClientAuth: "Configure client certificate in settings.yaml, if server requires one.",
+		CategoryNetworkTLSPin:        "Verify pinned keys in settings.yaml, or connection is intercepted by unknown party.",
+		CategoryNetworkTLSChain:      "Ask server administrators to serve complete certificate chain.",
+		CategoryNetworkTLSRoots:      "Add missing root certificate into system store or settings.yaml 'certificates' section.",
+		CategoryNetworkTLSIssuers:    "Confirm with network administrators that TLS inspecting proxy is expected.",
+		CategoryNetworkProxyRouting:  "Fix HTTP_PROXY, HTTPS_PROXY and NO_PROXY settings to route needed hosts correctly.",
+		CategoryNetworkProxyTunnel:   "Allow CONNECT tunnels to needed hosts in proxy, and check proxy credentials.",
+		CategoryNetworkProtocol:      "Allow HTTP/1.1 and HTTP/2 protocols through proxy and security appliances.",
+		CategoryNetworkALPN:          "Allow ALPN protocol negotiation through TLS inspecting proxy.",
+		CategoryNetworkTelemetry:     "Allow telemetry host in firewall, or disable telemetry in settings.",
+		CategoryNetworkUpload:        "Allow uploads to needed hosts in proxy and content filtering, and raise upload size limits.",
+		CategoryNetworkCaSources:     "Make certificate sources agree, by adding same roots into system store and settings.yaml.",
+		CategoryNetworkRange:         "Allow HTTP range requests through proxy, so that interrupted downloads can resume.",
+		CategoryEnvironmentCache:     "Check holotree catalogs with 'rcc holotree check' and remove broken ones.",
+		CategoryHolotreeBenchmark:    "Move ROBOCORP_HOME to faster local disk, and exclude it from antivirus scanning.",
+		CategoryEnvironmentBenchmark: "Speed up environment builds by improving network access, or by using prebuilt environments.",
+		CategoryMicromambaVersion:    "Remove micromamba from ROBOCORP_HOME, and let rcc install expected version.",
+		CategoryVirtualPackages:      "Match conda virtual packages (like __glibc) to what environments need, or upgrade operating system.",
+		CategoryBuildTools:           "Install compiler toolchain (like build-essential, Xcode command line tools, or Visual Studio Build Tools).",
+	}
+)
+
+// Remediation is next step advice for category, or empty when there is none.
+func Remediation(category uint64) string {
+	return remediations[category]
+}
@@ -0,0 +1,299 @@
+package common
+
+// Codes are stable numeric outcomes of diagnostic checks, so that alerting
+// can rely on them instead of changing message texts. Code is category
+// times ten plus outcome, where outcome zero is success. This is the code
+// catalog, so never renumber or reuse codes; add new outcomes instead.
+const (
+	CodeLongPathOk      = 10100
+	CodeLongPathMissing = 10101
+
+	CodeLockFileOk          = 10200
+	CodeLockFileWriteFailed = 10201
+
+	CodeLockPidOk         = 10210
+	CodeLockPidUnreadable = 10211
+	CodeLockPidActive     = 10212
+
+	CodeLockStaleOk   = 10220
+	CodeLockStaleHeld = 10221
+
+	CodePathVarOk         = 10300
+	CodePathVarSet        = 10301
+	CodeWorkdirInsideHome = 10302
+
+	CodeEnvVarOk  = 10400
+	CodeEnvVarSet = 10401
+
+	CodeEnvPollutionOk = 10410
+	CodeEnvPolluted    = 10411
+
+	CodeHomeVariableOk       = 10500
+	CodeHomeVariableUnset    = 10501
+	CodeHomeVariableMissing  = 10502
+	CodeHomeVariableReadOnly = 10503
+	CodeHomeVariablesDiffer  = 10504
+	CodeHomeVariablesAgree   = 10505
+
+	CodeMemoryOk      = 10600
+	CodeMemoryUnknown = 10601
+	CodeMemoryLow     = 10602
+
+	CodeEntropyModernKernel = 10610
+	CodeEntropyOk           = 10611
+	CodeEntropyUnknown      = 10612
+	CodeEntropyLow          = 10613
+
+	CodeCertificateStoreBundleOk = 10700
+	CodeCertificateStoreOk       = 10701
+	CodeCertificateStoreMissing  = 10702
+
+	CodeReadinessOk      = 10800
+	CodeReadinessBlocked = 10801
+
+	CodeRccOnPathOk    = 10900
+	CodeRccFirstOnPath = 10901
+	CodeRccShadowed    = 10902
+
+	CodeRccLocationOk  = 10910
+	CodeRccQuarantined = 10911
+	CodeRccInTemp      = 10912
+
+	CodeTempDirectoryOk       = 11000
+	CodeTempDirectoryUnusable = 11001
+
+	CodeListenerOk      = 11100
+	CodeListenerBlocked = 11101
+
+	CodeCpuAffinityOk         = 11200
+	CodeCpuAffinityUnknown    = 11201
+	CodeCpuAffinityRestricted = 11202
+
+	CodeClockSkewOk = 11300
+	CodeClockSkewed = 11301
+
+	CodeTimeZoneOk         = 11310
+	CodeTimeZoneInvalid    = 11311
+	CodeTimeZoneNoDatabase = 11312
+
+	CodeAntivirusExcluded    = 11400
+	CodeAntivirusUnknown     = 11401
+	CodeAntivirusNotExcluded = 11402
+
+	CodeWslOk           = 11500
+	CodeWslClockDrift   = 11501
+	CodeWslWindowsMount = 11502
+
+	CodeHolotreeSharedOk  = 20100
+	CodeHolotreeNotShared = 20101
+
+	CodeSharedModeOk         = 20200
+	CodeSharedModeGroupOnly  = 20201
+	CodeSharedModeUnreadable = 20202
+
+	CodeHolotreeSpaces = 20300
+
+	CodeRobocorpHomeOk          = 30100
+	CodeRobocorpHomeBadChars    = 30101
+	CodeRobocorpHomeHasUserHome = 30102
+
+	CodeRobocorpHomeSharedUsers = 30201
+
+	CodeConfigPermissionsOk = 30300
+	CodeConfigWritable      = 30301
+
+	CodeRobocorpHomeNamesOk  = 30400
+	CodeRobocorpHomeBadNames = 30401
+
+	CodeCaseSensitive          = 30500
+	CodeCaseSensitivityUnknown = 30501
+	CodeCaseNotPreserved       = 30502
+	CodeCaseInsensitive        = 30503
+
+	CodeDiskSpaceOk      = 30600
+	CodeDiskSpaceUnknown = 30601
+	CodeDiskSpaceLow     = 30602
+
+	CodeHomeFilesystemLocal   = 30700
+	CodeHomeFilesystemUnknown = 30701
+	CodeHomeOnNetwork         = 30702
+
+	CodeMicromambaIntact       = 30800
+	CodeMicromambaNotExtracted = 30801
+	CodeMicromambaUnreadable   = 30802
+	CodeMicromambaCorrupted    = 30803
+	CodeMicromambaNoDigest     = 30804
+
+	CodeHomeExecOk      = 30900
+	CodeHomeExecBlocked = 30901
+
+	CodeNetworkOffline = 40000
+
+	CodeDnsLookupOk      = 40100
+	CodeDnsLookupTimeout = 40101
+	CodeDnsLookupFailed  = 40102
+	CodeDnsLookupSlow    = 40103
+
+	CodeDnsTtlOk          = 40110
+	CodeDnsTtlUnsupported = 40111
+	CodeDnsTtlInvalidLink = 40112
+	CodeDnsTtlQueryFailed = 40113
+	CodeDnsTtlLow         = 40114
+
+	CodeDnsFamilyOk          = 40120
+	CodeDnsFamilyNoAddresses = 40121
+	CodeDnsFamilyFailed      = 40122
+	CodeDnsFamilyNoIPv4      = 40123
+	CodeDnsFamilyNoIPv6      = 40124
+
+	CodeHostsFileOk       = 40130
+	CodeHostsFileOverride = 40131
+
+	CodeNetworkClientFailed   = 40201
+	CodeNetworkConnectFailed  = 40202
+	CodeNetworkTlsHostInvalid = 40203
+
+	CodeHeadOk          = 40300
+	CodeHeadFailed      = 40301
+	CodeHeadStatus      = 40302
+	CodeHeadError       = 40303
+	CodeHeadFingerprint = 40304
+
+	CodeReachabilityOk          = 40310
+	CodeReachabilityFailed      = 40311
+	CodeReachabilityRedirected  = 40312
+	CodeReachabilityServerError = 40313
+
+	CodeCanaryOk          = 40400
+	CodeCanaryWrapped     = 40401
+	CodeCanaryStatus      = 40402
+	CodeCanaryError       = 40403
+	CodeCanaryFingerprint = 40404
+
+	CodeLargePayloadOk      = 40410
+	CodeLargePayloadStopped = 40411
+
+	CodeCaptivePortal = 40421
+
+	CodeCanaryDnsFailed = 40431
+
+	CodeCanaryTcpFailed = 40441
+
+	CodeCanaryTlsFailed = 40451
+
+	CodeCanaryHttpStatus = 40461
+	CodeCanaryHttpFailed = 40462
+
+	CodeThroughputOk  = 40470
+	CodeThroughputLow = 40471
+
+	CodeCanaryIPv6Ok     = 40480
+	CodeCanaryIPv6Failed = 40481
+	CodeCanaryIPv6Status = 40482
+
+	CodeTlsVersionOk          = 40500
+	CodeTlsVersionBelowPolicy = 40501
+	CodeTlsVersionOld         = 40502
+	CodeTlsVersionUnknown     = 40503
+
+	CodeTlsMinimumOk      = 40510
+	CodeTlsMinimumUnknown = 40511
+	CodeTlsMinimumFailed  = 40512
+	CodeTlsMinimumNoTls   = 40513
+
+	CodeTlsCipherOk   = 40520
+	CodeTlsCipherWeak = 40521
+
+	CodeTlsVerifyOk       = 40600
+	CodeTlsNoCertificates = 40601
+	CodeTlsVerifyFailed   = 40602
+
+	CodeTlsExpiryOk = 40610
+	CodeTlsExpired  = 40611
+	CodeTlsExpiring = 40612
+
+	CodeOcspNotStapled = 40620
+	CodeOcspGood       = 40621
+	CodeOcspUnverified = 40622
+	CodeOcspRevoked    = 40623
+	CodeOcspUnknown    = 40624
+
+	CodeClientCertAccepted     = 40630
+	CodeClientCertNotRequested = 40631
+	CodeClientCertInvalid      = 40632
+	CodeClientCertMissing      = 40633
+	CodeClientCertRejected     = 40634
+
+	CodeTlsPinOk       = 40640
+	CodeTlsPinMismatch = 40641
+
+	CodeTlsChain = 40701
+
+	CodeTlsRootsOk            = 40710
+	CodeTlsRootsBundleOk      = 40711
+	CodeTlsRootsBundleEmpty   = 40712
+	CodeTlsRootsBundleIgnored = 40713
+
+	CodeTlsIssuersOk         = 40720
+	CodeTlsIssuersUnverified = 40721
+
+	CodeProxyBypassed       = 40800
+	CodeProxyDirect         = 40801
+	CodeProxyRouted         = 40802
+	CodeNoProxyProblems     = 40803
+	CodeProxyRoutingUnknown = 40804
+	CodeProxyBypassIgnored  = 40805
+	CodeProxyInternalHost   = 40806
+
+	CodeProxyNotNeeded     = 40810
+	CodeProxyTunnelOk      = 40811
+	CodeProxyCanaryInvalid = 40812
+	CodeProxyUnknown       = 40813
+	CodeProxyHintsIgnored  = 40814
+	CodeProxyTunnelFailed  = 40815
+
+	CodeProtocolKeepAlive       = 40900
+	CodeProtocolLegacy          = 40901
+	CodeProtocolConnectionClose = 40902
+
+	CodeTlsAlpn = 40910
+
+	CodeTelemetryOk       = 41000
+	CodeTelemetryUnusable = 41001
+	CodeTelemetryBlocked  = 41002
+
+	CodeUploadOk            = 41100
+	CodeUploadNotConfigured = 41101
+	CodeUploadInvalidLink   = 41102
+	CodeUploadBlocked       = 41103
+
+	CodeCaSourcesConsistent = 41200
+	CodeCaSourcesDiffer     = 41201
+
+	CodeRangeOk       = 41300
+	CodeRangeUnusable = 41301
+	CodeRangeFailed   = 41302
+	CodeRangeIgnored  = 41303
+
+	CodeIoBenchmarkOk     = 50200
+	CodeIoBenchmarkFailed = 50201
+	CodeIoBenchmarkSlow   = 50202
+
+	CodeEnvBenchmarkOk     = 50210
+	CodeEnvBenchmarkFailed = 50211
+
+	CodeMicromambaVersionOk       = 50300
+	CodeMicromambaNotInstalled    = 50301
+	CodeMicromambaVersionMismatch = 50302
+	CodeMicromambaUnrunnable      = 50303
+
+	CodeVirtualPackagesOk      = 50400
+	CodeVirtualPackagesSkipped = 50401
+	CodeVirtualPackagesUnknown = 50402
+	CodeVirtualPackageTooOld   = 50403
+
+	CodeBuildToolchainOk      = 50500
+	CodeBuildToolsOk          = 50501
+	CodeBuildToolchainMissing = 50502
+	CodeBuildToolsMissing     = 50503
+)
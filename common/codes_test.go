@@ -0,0 +1,53 @@
+package common_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func constants(t *testing.T, filename, prefix string) map[string]uint64 {
+	tree, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]uint64)
+	for _, object := range tree.Scope.Objects {
+		spec, ok := object.Decl.(*ast.ValueSpec)
+		if !ok || object.Kind != ast.Con || len(spec.Values) != 1 {
+			continue
+		}
+		literal, ok := spec.Values[0].(*ast.BasicLit)
+		if !ok || len(object.Name) <= len(prefix) || object.Name[:len(prefix)] != prefix {
+			continue
+		}
+		value, err := strconv.ParseUint(literal.Value, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[object.Name] = value
+	}
+	return result
+}
+
+func TestCodeCatalogIsUniqueAndFollowsCategories(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	categories := make(map[uint64]bool)
+	for _, value := range constants(t, "categories.go", "Category") {
+		categories[value] = true
+	}
+	codes := constants(t, "codes.go", "Code")
+	wont_be.Equal(0, len(codes))
+	seen := make(map[uint64]string)
+	for name, code := range codes {
+		_, duplicate := seen[code]
+		wont_be.True(duplicate)
+		seen[code] = name
+		must_be.True(categories[code/10])
+	}
+}
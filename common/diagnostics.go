@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/robocorp/rcc/fail"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -13,6 +15,13 @@ const (
 	StatusWarning = `warning`
 	StatusFail    = `fail`
 	StatusFatal   = `fatal`
+
+	// DiagnosticsSchema is version of JSON/YAML shape of DiagnosticStatus.
+	// Consumers can rely on it: adding optional fields keeps it same, but
+	// removing, renaming, or changing meaning of fields bumps it. Added
+	// fields must still be listed in assets/diagnostics_schema.json, since
+	// tests validate output strictly against it.
+	DiagnosticsSchema = 1
 )
 
 type Diagnoser func(category uint64, status, link, form string, details ...interface{})
@@ -34,22 +43,38 @@ func (it Diagnoser) Fatal(category uint64, link, form string, details ...interfa
 }
 
 type DiagnosticStatus struct {
-	Details map[string]string  `json:"details"`
-	Checks  []*DiagnosticCheck `json:"checks"`
+	Schema     int                `json:"schema" yaml:"schema"`
+	Readiness  *DiagnosticCheck   `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+	Details    map[string]string  `json:"details" yaml:"details"`
+	Checks     []*DiagnosticCheck `json:"checks" yaml:"checks"`
+	NextSteps  []string           `json:"next-steps,omitempty" yaml:"next-steps,omitempty"`
+	Summary    *DiagnosticSummary `json:"summary,omitempty" yaml:"summary,omitempty"`
+	DurationMs int64              `json:"duration-ms,omitempty" yaml:"duration-ms,omitempty"`
+}
+
+type DiagnosticSummary struct {
+	Statuses   map[string]int `json:"statuses" yaml:"statuses"`
+	Categories map[string]int `json:"categories" yaml:"categories"`
+	Types      map[string]int `json:"types" yaml:"types"`
 }
 
 type DiagnosticCheck struct {
-	Type     string `json:"type"`
-	Category uint64 `json:"category"`
-	Status   string `json:"status"`
-	Message  string `json:"message"`
-	Link     string `json:"url"`
+	Type       string `json:"type" yaml:"type"`
+	Category   uint64 `json:"category" yaml:"category"`
+	Code       uint64 `json:"code" yaml:"code"`
+	Status     string `json:"status" yaml:"status"`
+	Message    string `json:"message" yaml:"message"`
+	Link       string `json:"url" yaml:"url"`
+	DurationMs int64  `json:"duration-ms,omitempty" yaml:"duration-ms,omitempty"`
+	// Command is optional concrete remediation command for this problem.
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
 }
 
 func (it *DiagnosticStatus) check(category uint64, kind, status, message, link string) {
 	it.Checks = append(it.Checks, &DiagnosticCheck{
 		Type:     kind,
 		Category: category,
+		Code:     DiagnosticCode(category, status),
 		Status:   status,
 		Message:  message,
 		Link:     link,
@@ -70,8 +95,221 @@ func (it *DiagnosticStatus) Counts() (fatal, fail, warning, ok int) {
 	return result[StatusFatal], result[StatusFail], result[StatusWarning], result[StatusOk]
 }
 
+// Summarize tallies checks by status, by category code, and by check type.
+func (it *DiagnosticStatus) Summarize() *DiagnosticSummary {
+	result := &DiagnosticSummary{
+		Statuses:   make(map[string]int),
+		Categories: make(map[string]int),
+		Types:      make(map[string]int),
+	}
+	for _, check := range it.Checks {
+		result.Statuses[check.Status] += 1
+		result.Categories[fmt.Sprintf("%d", check.Category)] += 1
+		result.Types[check.Type] += 1
+	}
+	return result
+}
+
+// ExitCode maps worst check status to process exit code, so that
+// pipelines can gate on diagnostics without parsing output.
+func (it *DiagnosticStatus) ExitCode(strict bool) int {
+	fatal, fail, warning, _ := it.Counts()
+	switch {
+	case fatal > 0:
+		return 3
+	case fail > 0:
+		return 2
+	case warning > 0 && strict:
+		return 1
+	}
+	return 0
+}
+
+// Remediations lists next steps for not ok checks, most severe first. Checks
+// of same category share one step, and checks without catalogued advice
+// fall back to their own message.
+func (it *DiagnosticStatus) Remediations() []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, status := range []string{StatusFatal, StatusFail, StatusWarning} {
+		for _, check := range it.Checks {
+			if check.Status != status {
+				continue
+			}
+			key, advice := check.Message, Remediation(check.Category)
+			if len(advice) > 0 {
+				key = fmt.Sprintf("%d", check.Category)
+			} else {
+				advice = check.Message
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			step := fmt.Sprintf("[%s] %s", check.Status, advice)
+			if len(check.Command) > 0 {
+				step = fmt.Sprintf("%s (run: %s)", step, check.Command)
+			}
+			if len(check.Link) > 0 {
+				step = fmt.Sprintf("%s (see: %s)", step, check.Link)
+			}
+			result = append(result, step)
+		}
+	}
+	return result
+}
+
+// InCategory tells if check matches any of given check types or numeric
+// category codes.
+func (it *DiagnosticCheck) InCategory(categories ...string) bool {
+	code := fmt.Sprintf("%d", it.Category)
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		if strings.EqualFold(category, it.Type) || category == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByCategory returns copy with only checks matching any of given
+// categories, which are either check types (like "network") or numeric
+// category codes (like "4060").
+func (it *DiagnosticStatus) FilterByCategory(categories ...string) *DiagnosticStatus {
+	result := &DiagnosticStatus{
+		Readiness: it.Readiness,
+		Details:   make(map[string]string),
+		Checks:    []*DiagnosticCheck{},
+	}
+	for key, value := range it.Details {
+		result.Details[key] = value
+	}
+	for _, check := range it.Checks {
+		if check.InCategory(categories...) {
+			result.Checks = append(result.Checks, check)
+		}
+	}
+	if it.NextSteps != nil {
+		result.NextSteps = result.Remediations()
+	}
+	return result
+}
+
+// StatusSeverity orders check statuses from ok (0) to fatal (3), and gives
+// -1 for unknown status.
+func StatusSeverity(status string) int {
+	switch status {
+	case StatusOk:
+		return 0
+	case StatusWarning:
+		return 1
+	case StatusFail:
+		return 2
+	case StatusFatal:
+		return 3
+	}
+	return -1
+}
+
+// FilterByStatus returns copy with only checks at least as severe as given
+// threshold status; checks is empty (not nil) when nothing reaches it.
+func (it *DiagnosticStatus) FilterByStatus(threshold string) *DiagnosticStatus {
+	result := &DiagnosticStatus{
+		Readiness:  it.Readiness,
+		Details:    make(map[string]string),
+		Checks:     []*DiagnosticCheck{},
+		DurationMs: it.DurationMs,
+	}
+	for key, value := range it.Details {
+		result.Details[key] = value
+	}
+	limit := StatusSeverity(threshold)
+	for _, check := range it.Checks {
+		if StatusSeverity(check.Status) >= limit {
+			result.Checks = append(result.Checks, check)
+		}
+	}
+	if it.NextSteps != nil {
+		result.NextSteps = result.Remediations()
+	}
+	return result
+}
+
+func (it *DiagnosticStatus) OmitDetails(keys []string) {
+	for _, key := range keys {
+		delete(it.Details, key)
+	}
+}
+
+// Anonymized returns stable placeholder for identifying value, so that
+// same machine still gives same placeholder on every run.
+func Anonymized(value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	return fmt.Sprintf("anonymized-%s", ShortDigest(value))
+}
+
+// Anonymizer gives replacer for values of given detail keys, or nil when
+// there is nothing to replace.
+func (it *DiagnosticStatus) Anonymizer(keys []string) *strings.Replacer {
+	replacements := []string{}
+	for _, key := range keys {
+		value, ok := it.Details[key]
+		if !ok || len(value) == 0 {
+			continue
+		}
+		replacements = append(replacements, value, Anonymized(value))
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// Anonymize replaces values of given detail keys with their placeholders,
+// and also every occurrence of those values in other details and checks.
+func (it *DiagnosticStatus) Anonymize(keys []string) {
+	replacer := it.Anonymizer(keys)
+	if replacer == nil {
+		return
+	}
+	for key, value := range it.Details {
+		it.Details[key] = replacer.Replace(value)
+	}
+	for _, check := range it.Checks {
+		check.Message = replacer.Replace(check.Message)
+	}
+}
+
+// stamped is shallow copy with schema version and summary filled in, so
+// that serializing does not modify status itself
+func (it *DiagnosticStatus) stamped() *DiagnosticStatus {
+	result := *it
+	result.Schema = DiagnosticsSchema
+	result.Summary = it.Summarize()
+	return &result
+}
+
 func (it *DiagnosticStatus) AsJson() (string, error) {
-	body, err := json.MarshalIndent(it, "", "  ")
+	body, err := json.MarshalIndent(it.stamped(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// AsJsonLine is compact single line form, for JSONL logs
+func (it *DiagnosticStatus) AsJsonLine() (string, error) {
+	body, err := json.Marshal(it.stamped())
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func (it *DiagnosticStatus) AsYaml() (string, error) {
+	body, err := yaml.Marshal(it.stamped())
 	if err != nil {
 		return "", err
 	}
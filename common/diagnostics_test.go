@@ -0,0 +1,259 @@
+package common_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanOmitDiagnosticDetails(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"executable": "/home/user/rcc", "rcc": "v1", "hostname": "box"},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	sut.OmitDetails([]string{"executable", "hostname", "missing"})
+	must_be.Equal(1, len(sut.Details))
+	must_be.Equal("v1", sut.Details["rcc"])
+	_, ok := sut.Details["executable"]
+	wont_be.True(ok)
+
+	sut.OmitDetails(nil)
+	must_be.Equal(1, len(sut.Details))
+}
+
+func TestCanAnonymizeDiagnosticDetails(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"installationId": "1234-abcd", "controller": "rcc.user", "user-agent": "rcc/v1 (linux amd64) rcc.user", "rcc": "v1"},
+		Checks:  []*common.DiagnosticCheck{{Message: "identity 1234-abcd seen"}},
+	}
+	sut.Anonymize([]string{"installationId", "controller", "missing"})
+	must_be.Equal(common.Anonymized("1234-abcd"), sut.Details["installationId"])
+	must_be.Equal(common.Anonymized("rcc.user"), sut.Details["controller"])
+	must_be.True(strings.HasPrefix(sut.Details["installationId"], "anonymized-"))
+	must_be.Equal("v1", sut.Details["rcc"])
+	wont_be.True(strings.Contains(sut.Details["user-agent"], "rcc.user"))
+	wont_be.True(strings.Contains(sut.Checks[0].Message, "1234-abcd"))
+	must_be.Equal(common.Anonymized("1234-abcd"), common.Anonymized("1234-abcd"))
+	must_be.Equal("", common.Anonymized(""))
+}
+
+func TestCanSynthesizeOrderedNextSteps(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	must_be.Equal(0, len(sut.Remediations()))
+
+	diagnose := sut.Diagnose("test")
+	diagnose.Warning(0, "https://a/", "first warning")
+	diagnose.Ok(0, "all good")
+	diagnose.Fail(0, "", "some failure")
+	diagnose.Warning(0, "https://a/", "first warning")
+	diagnose.Fatal(0, "https://b/", "blocking")
+
+	steps := sut.Remediations()
+	must_be.Equal(3, len(steps))
+	must_be.Equal("[fatal] blocking (see: https://b/)", steps[0])
+	must_be.Equal("[fail] some failure", steps[1])
+	must_be.Equal("[warning] first warning (see: https://a/)", steps[2])
+
+	sut.Checks[2].Command = "nslookup example.com"
+	must_be.Equal("[fail] some failure (run: nslookup example.com)", sut.Remediations()[1])
+}
+
+func TestNextStepsUseRemediationOfCategory(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	diagnose := sut.Diagnose("test")
+	diagnose.Warning(common.CategoryNetworkDNS, "", "slow resolve of a.example")
+	diagnose.Fail(common.CategoryNetworkDNS, "", "no resolve of b.example")
+	diagnose.Fail(common.CategoryDiskSpace, "", "disk is full")
+
+	advice := common.Remediation(common.CategoryNetworkDNS)
+	wont_be.Equal("", advice)
+	steps := sut.Remediations()
+	must_be.Equal(2, len(steps))
+	must_be.Equal("[fail] "+advice, steps[0])
+	must_be.Equal("[fail] "+common.Remediation(common.CategoryDiskSpace), steps[1])
+	wont_be.True(strings.Contains(steps[1], "disk is full"))
+}
+
+func TestCanAssignStableDiagnosticCodes(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(uint64(40100), common.DiagnosticCode(common.CategoryNetworkDNS, common.StatusOk))
+	must_be.Equal(uint64(40102), common.DiagnosticCode(common.CategoryNetworkDNS, common.StatusFail))
+	must_be.Equal(uint64(10203), common.DiagnosticCode(common.CategoryLockFile, common.StatusFatal))
+	must_be.Equal(uint64(10109), common.DiagnosticCode(common.CategoryLongPath, "unknown"))
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks: []*common.DiagnosticCheck{
+			&common.DiagnosticCheck{Category: common.CategoryRobocorpHome, Code: common.CodeRobocorpHomeHasUserHome, Status: common.StatusWarning},
+		},
+	}
+	sut.Diagnose("OS").Fail(common.CategoryLongPath, "", "no long paths")
+	must_be.Equal(uint64(10102), sut.Checks[1].Code)
+
+	body, err := sut.AsJson()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(body, `"code": 30102`))
+	must_be.Equal(uint64(common.CodeRobocorpHomeHasUserHome), sut.Checks[0].Code)
+	must_be.Nil(sut.Summary)
+	must_be.Equal(0, sut.Schema)
+}
+
+func TestCanProduceDiagnosticsAsYaml(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"zulu": "last", "alpha": "first"},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	sut.Diagnose("OS").Warning(common.CategoryLongPath, "https://a/", "long paths")
+	body, err := sut.AsYaml()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(body, "details:\n  alpha: first\n  zulu: last\n"))
+	must_be.True(strings.Contains(body, "code: 10101"))
+	must_be.True(strings.Contains(body, "url: https://a/"))
+	wont_be.True(strings.Contains(body, "readiness:"))
+	must_be.True(strings.HasPrefix(body, "schema: 1\n"))
+}
+
+func TestCanMapWorstStatusToExitCode(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	must_be.Equal(0, sut.ExitCode(true))
+	diagnose := sut.Diagnose("test")
+	diagnose.Ok(0, "fine")
+	diagnose.Warning(0, "", "hmm")
+	must_be.Equal(0, sut.ExitCode(false))
+	must_be.Equal(1, sut.ExitCode(true))
+	diagnose.Fail(0, "", "broken")
+	must_be.Equal(2, sut.ExitCode(false))
+	diagnose.Fatal(0, "", "blocked")
+	must_be.Equal(3, sut.ExitCode(false))
+	must_be.Equal(3, sut.ExitCode(true))
+}
+
+func TestCanFilterDiagnosticsByCategory(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"key": "value"},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	sut.Diagnose("OS").Ok(common.CategoryLongPath, "long paths")
+	sut.Diagnose("network").Fail(common.CategoryNetworkDNS, "", "no dns")
+	sut.Diagnose("network").Ok(common.CategoryNetworkTLSVerify, "tls ok")
+
+	network := sut.FilterByCategory("Network")
+	must_be.Equal(2, len(network.Checks))
+	must_be.Equal("value", network.Details["key"])
+	must_be.Equal(3, len(sut.Checks))
+
+	network.Details["key"] = "changed"
+	must_be.Equal("value", sut.Details["key"])
+
+	tls := sut.FilterByCategory("4060", "RPA")
+	must_be.Equal(1, len(tls.Checks))
+	must_be.Equal("tls ok", tls.Checks[0].Message)
+
+	wont_be.Nil(sut.FilterByCategory("nothing").Checks)
+	must_be.Equal(0, len(sut.FilterByCategory().Checks))
+}
+
+func TestCanProduceDiagnosticsAsJsonLine(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"alpha": "first"},
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	sut.Diagnose("OS").Ok(common.CategoryLongPath, "long paths")
+	body, err := sut.AsJsonLine()
+	must_be.Nil(err)
+	wont_be.True(strings.Contains(body, "\n"))
+	must_be.True(strings.HasPrefix(body, `{"schema":1,`))
+	must_be.True(strings.Contains(body, `"code":10100`))
+	wont_be.True(strings.Contains(body, `"duration-ms"`))
+
+	sut.DurationMs = 1234
+	body, err = sut.AsJsonLine()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(body, `"duration-ms":1234`))
+}
+
+func TestCanSummarizeDiagnosticChecks(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks: []*common.DiagnosticCheck{
+			{Type: "OS", Category: common.CategoryLongPath, Status: common.StatusOk},
+			{Type: "OS", Category: common.CategoryLongPath, Status: common.StatusWarning},
+			{Type: "network", Category: common.CategoryNetworkDNS, Status: common.StatusOk},
+			{Type: "network", Category: common.CategoryNetworkCanary, Status: common.StatusFail},
+		},
+	}
+	summary := sut.Summarize()
+	must_be.Equal(2, summary.Statuses[common.StatusOk])
+	must_be.Equal(1, summary.Statuses[common.StatusWarning])
+	must_be.Equal(1, summary.Statuses[common.StatusFail])
+	must_be.Equal(2, summary.Categories[fmt.Sprintf("%d", common.CategoryLongPath)])
+	must_be.Equal(1, summary.Categories[fmt.Sprintf("%d", common.CategoryNetworkCanary)])
+	must_be.Equal(2, summary.Types["OS"])
+	must_be.Equal(2, summary.Types["network"])
+
+	body, err := sut.AsJson()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(body, `"summary"`))
+}
+
+func TestCanFilterChecksByStatus(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	sut := &common.DiagnosticStatus{
+		Details: map[string]string{"rcc": "v17"},
+		Checks: []*common.DiagnosticCheck{
+			{Type: "OS", Status: common.StatusOk},
+			{Type: "OS", Status: common.StatusWarning},
+			{Type: "network", Status: common.StatusFail},
+			{Type: "RPA", Status: common.StatusFatal},
+		},
+	}
+	must_be.Equal(3, len(sut.FilterByStatus(common.StatusWarning).Checks))
+	must_be.Equal(2, len(sut.FilterByStatus(common.StatusFail).Checks))
+	must_be.Equal(1, len(sut.FilterByStatus(common.StatusFatal).Checks))
+	must_be.Equal("v17", sut.FilterByStatus(common.StatusFatal).Details["rcc"])
+
+	healthy := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks:  []*common.DiagnosticCheck{{Type: "OS", Status: common.StatusOk}},
+	}
+	filtered := healthy.FilterByStatus(common.StatusWarning)
+	wont_be.Nil(filtered.Checks)
+	must_be.Equal(0, len(filtered.Checks))
+	body, err := filtered.AsJson()
+	must_be.Nil(err)
+	must_be.True(strings.Contains(body, `"checks": []`))
+
+	must_be.Equal(-1, common.StatusSeverity("bogus"))
+}
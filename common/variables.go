@@ -58,6 +58,7 @@ var (
 	StageFolder             string
 	ControllerType          string
 	HolotreeSpace           string
+	IpFamily                string
 	EnvironmentHash         string
 	SemanticTag             string
 	ForcedRobocorpHome      string
@@ -1,5 +1,5 @@
 package common
 
 const (
-	Version = `v17.26.0`
+	Version = `v17.126.0`
 )
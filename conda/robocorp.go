@@ -2,6 +2,7 @@ package conda
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -257,14 +258,42 @@ func PipVersion(python string) string {
 }
 
 func MicromambaVersion() string {
-	versionText, _, err := shell.New(CondaEnvironment(), ".", BinMicromamba(), "--repodata-ttl", "90000", "--version").CaptureOutput()
+	versionText, err := ProbeMicromambaVersion()
 	if err != nil {
 		return err.Error()
 	}
-	_, versionText = AsVersion(versionText)
 	return versionText
 }
 
+// ProbeMicromambaVersion is like MicromambaVersion, but tells failure to run
+// micromamba apart from version text
+func ProbeMicromambaVersion() (string, error) {
+	versionText, code, err := shell.New(CondaEnvironment(), ".", BinMicromamba(), "--repodata-ttl", "90000", "--version").CaptureOutput()
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", fmt.Errorf("micromamba --version exited with code %d", code)
+	}
+	_, versionText = AsVersion(versionText)
+	return versionText, nil
+}
+
+func MicromambaVirtualPackages() ([]string, error) {
+	output, _, err := shell.New(CondaEnvironment(), ".", BinMicromamba(), "info", "--json").CaptureOutput()
+	if err != nil {
+		return nil, err
+	}
+	info := struct {
+		Virtual []string `json:"virtual packages"`
+	}{}
+	err = json.Unmarshal([]byte(output), &info)
+	if err != nil {
+		return nil, err
+	}
+	return info.Virtual, nil
+}
+
 func HasMicroMamba() bool {
 	if !pathlib.IsFile(BinMicromamba()) {
 		return false
@@ -65,7 +65,7 @@ func NewEnvironment(condafile, holozip string, restore, force bool, puller Catal
 		if len(path) > 0 {
 			dependencies := conda.LoadWantedDependencies(conda.GoldenMasterFilename(path))
 			dependencies.WarnVulnerability(
-				"https://robocorp.com/docs/faq/openssl-cve-2022-11-01",
+				settings.Global.DocsLink("faq/openssl-cve-2022-11-01"),
 				"HIGH",
 				"openssl",
 				"3.0.0", "3.0.1", "3.0.2", "3.0.3", "3.0.4", "3.0.5", "3.0.6")
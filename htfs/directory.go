@@ -129,6 +129,18 @@ func (it Roots) Spaces() Roots {
 	return roots
 }
 
+// BlueprintCounts tells how many of these spaces are materialized from each
+// blueprint (environment hash).
+func (it Roots) BlueprintCounts() map[string]int {
+	result := make(map[string]int)
+	for _, root := range it {
+		if root.Info != nil {
+			result[root.Blueprint] += 1
+		}
+	}
+	return result
+}
+
 func (it Roots) Spacemap() map[string]string {
 	result := make(map[string]string)
 	for _, basedir := range it.BaseFolders() {
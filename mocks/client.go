@@ -65,6 +65,10 @@ func (it *MockClient) Get(request *cloud.Request) *cloud.Response {
 	return it.does("GET", request)
 }
 
+func (it *MockClient) GetWithRetry(request *cloud.Request, attempts int) *cloud.Response {
+	return it.does("GET", request)
+}
+
 func (it *MockClient) Post(request *cloud.Request) *cloud.Response {
 	return it.does("POST", request)
 }
@@ -189,7 +189,7 @@ func MultipartUpload(url string, fields map[string]string, basename, fullpath st
 	}
 	request.Header.Add("Content-Type", many.FormDataContentType())
 	request.Header.Add("User-Agent", common.UserAgent())
-	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	client := &http.Client{Transport: settings.Global.SharedHttpTransport()}
 	response, err := client.Do(request)
 	if err != nil {
 		return err
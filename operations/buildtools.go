@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+func buildToolsCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	toolchain, err := buildToolchain()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryBuildTools,
+			Code:     common.CodeBuildToolchainMissing,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%v. Environments that need to build packages from source will fail.", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if len(toolchain) > 0 {
+		details["build-toolchain"] = toolchain
+	}
+	searchPath := pathlib.TargetPath()
+	found, missing := []string{}, []string{}
+	for _, tool := range buildTools {
+		location, ok := searchPath.Which(tool, conda.FileExtensions)
+		if !ok {
+			missing = append(missing, tool)
+			continue
+		}
+		version := toolVersion(location, "--version")
+		details[fmt.Sprintf("build-tool:%s", tool)] = fmt.Sprintf("%s [%s]", location, version)
+		found = append(found, tool)
+	}
+	if len(missing) > 0 {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryBuildTools,
+			Code:     common.CodeBuildToolsMissing,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Build tools missing from PATH: %s (found: %s). Environments that need to build packages from source will fail.", strings.Join(missing, ", "), strings.Join(found, ", ")),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if len(found) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryBuildTools,
+			Code:     common.CodeBuildToolchainOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Build toolchain found at %q.", toolchain),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryBuildTools,
+		Code:     common.CodeBuildToolsOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Build tools found from PATH: %s.", strings.Join(found, ", ")),
+		Link:     supportGeneralUrl,
+	}
+}
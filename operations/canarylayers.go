@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+type canaryLayer struct {
+	category uint64
+	code     uint64
+	name     string
+	detail   string
+}
+
+func (it *canaryLayer) String() string {
+	if len(it.detail) == 0 {
+		return it.name
+	}
+	return fmt.Sprintf("%s [%s]", it.name, it.detail)
+}
+
+func defaultPort(link *url.URL) string {
+	if len(link.Port()) > 0 {
+		return link.Port()
+	}
+	if link.Scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+// probeLayers redoes failed request step by step (DNS resolution, TCP
+// connect, TLS handshake), to tell which layer fails; if all of those work,
+// problem is on HTTP level (or in proxy, which only HTTP level sees)
+func probeLayers(link string, transport *http.Transport, timeout time.Duration) *canaryLayer {
+	target, err := url.Parse(link)
+	if err != nil {
+		return &canaryLayer{common.CategoryNetworkCanaryHTTP, common.CodeCanaryHttpFailed, "HTTP level", err.Error()}
+	}
+	host, port := target.Hostname(), defaultPort(target)
+	proxied := false
+	if transport.Proxy != nil {
+		proxy, err := transport.Proxy(&http.Request{URL: target})
+		if err == nil && proxy != nil {
+			host, port, proxied = proxy.Hostname(), defaultPort(proxy), true
+		}
+	}
+	what := "DNS resolution"
+	if proxied {
+		what = "DNS resolution of proxy"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addresses, err := lookupAddresses(ctx, net.DefaultResolver, settings.Global.IpNetwork(), host)
+	if err != nil {
+		return &canaryLayer{common.CategoryNetworkCanaryDNS, common.CodeCanaryDnsFailed, what, fmt.Sprintf("%q: %v", host, err)}
+	}
+	what = "TCP connection"
+	if proxied {
+		what = "TCP connection to proxy"
+	}
+	address := net.JoinHostPort(addresses[0], port)
+	dialer := &net.Dialer{Timeout: timeout}
+	connection, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return &canaryLayer{common.CategoryNetworkCanaryTCP, common.CodeCanaryTcpFailed, what, fmt.Sprintf("%s: %v", address, err)}
+	}
+	defer connection.Close()
+	if proxied {
+		return &canaryLayer{common.CategoryNetworkCanaryHTTP, common.CodeCanaryHttpFailed, "HTTP level or proxy", fmt.Sprintf("proxy %s accepts connections", address)}
+	}
+	if target.Scheme != "https" {
+		return &canaryLayer{common.CategoryNetworkCanaryHTTP, common.CodeCanaryHttpFailed, "HTTP level", ""}
+	}
+	config := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		config = transport.TLSClientConfig.Clone()
+	}
+	config.ServerName = target.Hostname()
+	secure := tls.Client(connection, config)
+	err = secure.HandshakeContext(ctx)
+	if err != nil {
+		return &canaryLayer{common.CategoryNetworkCanaryTLS, common.CodeCanaryTlsFailed, "TLS handshake", fmt.Sprintf("%s: %v", target.Hostname(), err)}
+	}
+	return &canaryLayer{common.CategoryNetworkCanaryHTTP, common.CodeCanaryHttpFailed, "HTTP level", ""}
+}
@@ -0,0 +1,39 @@
+package operations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestProbeLayersTellsWhichLayerFails(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	trusting := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()}}
+	trusting.TLSClientConfig.RootCAs.AddCert(server.Certificate())
+	distrusting := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()}}
+
+	layer := probeLayers(server.URL, trusting, 5*time.Second)
+	must_be.Equal(uint64(common.CategoryNetworkCanaryHTTP), layer.category)
+
+	layer = probeLayers(server.URL, distrusting, 5*time.Second)
+	must_be.Equal(uint64(common.CategoryNetworkCanaryTLS), layer.category)
+	must_be.Equal(uint64(common.CodeCanaryTlsFailed), layer.code)
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedUrl := closed.URL
+	closed.Close()
+	layer = probeLayers(closedUrl, trusting, 5*time.Second)
+	must_be.Equal(uint64(common.CategoryNetworkCanaryTCP), layer.category)
+	must_be.True(strings.HasPrefix(layer.String(), "TCP connection ["))
+}
@@ -0,0 +1,82 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+func caseSensitiveAt(directory string) (sensitive, preserving bool, err error) {
+	defer fail.Around(&err)
+
+	folder, err := os.MkdirTemp(directory, ".rcc_case_probe")
+	fail.On(err != nil, "Could not create directory under %q, reason: %v", directory, err)
+	defer os.RemoveAll(folder)
+
+	mixed := filepath.Join(folder, "Probe.Txt")
+	err = os.WriteFile(mixed, []byte("mixed"), 0o644)
+	fail.On(err != nil, "Could not write %q, reason: %v", mixed, err)
+	entries, err := os.ReadDir(folder)
+	fail.On(err != nil, "Could not list %q, reason: %v", folder, err)
+	for _, entry := range entries {
+		preserving = preserving || entry.Name() == "Probe.Txt"
+	}
+	upper := filepath.Join(folder, "PROBE.TXT")
+	file, err := os.OpenFile(upper, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return false, preserving, nil
+	}
+	fail.On(err != nil, "Could not write %q, reason: %v", upper, err)
+	file.Close()
+	return true, preserving, nil
+}
+
+func caseSensitivityCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	home := common.RobocorpHome()
+	sensitive, preserving, err := caseSensitiveAt(home)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCaseSensitivity,
+			Code:     common.CodeCaseSensitivityUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not detect filesystem case sensitivity in %q, reason: %v", home, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["robocorp-home-case-sensitive"] = fmt.Sprintf("%v", sensitive)
+	details["robocorp-home-case-preserving"] = fmt.Sprintf("%v", preserving)
+	if !preserving {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCaseSensitivity,
+			Code:     common.CodeCaseNotPreserved,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Filesystem of %q is case-insensitive and does not even preserve case of file names. Package files differing only by case will collide, and names may change. Move ROBOCORP_HOME to another filesystem.", home),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if !sensitive {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCaseSensitivity,
+			Code:     common.CodeCaseInsensitive,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Filesystem of %q is case-insensitive (but case-preserving). Package files differing only by case will collide. Consider case-sensitive volume for ROBOCORP_HOME.", home),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryCaseSensitivity,
+		Code:     common.CodeCaseSensitive,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Filesystem of %q is case-sensitive.", home),
+		Link:     supportGeneralUrl,
+	}
+}
@@ -0,0 +1,24 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanProbeCaseBehaviourOfFilesystem(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	directory := t.TempDir()
+	_, preserving, err := caseSensitiveAt(directory)
+	must_be.Nil(err)
+	must_be.True(preserving)
+	entries, err := os.ReadDir(directory)
+	must_be.Nil(err)
+	must_be.Equal(0, len(entries))
+
+	_, _, err = caseSensitiveAt(filepath.Join(directory, "missing"))
+	wont_be.Nil(err)
+}
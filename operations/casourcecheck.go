@@ -0,0 +1,129 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	systemStoreSource = `system certificate store`
+	noVerifySource    = `verification disabled`
+)
+
+var (
+	systemCaBundles = []string{
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/tls/certs/ca-bundle.crt",
+		"/etc/ssl/ca-bundle.pem",
+		"/etc/pki/tls/cacert.pem",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/ssl/cert.pem",
+	}
+)
+
+func sameCaFile(left, right string) bool {
+	return len(left) > 0 && len(right) > 0 && realPath(left) == realPath(right)
+}
+
+func micromambaRcSslVerify() (string, bool) {
+	if !settings.Global.HasMicroMambaRc() {
+		return "", false
+	}
+	content, err := os.ReadFile(common.MicroMambaRcFile())
+	if err != nil {
+		return "", false
+	}
+	config := make(map[string]interface{})
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return "", false
+	}
+	value, ok := config["ssl_verify"]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+func caFileSource(value string) (source, file string) {
+	switch value {
+	case "false", "False", "0":
+		return noVerifySource, ""
+	case "", "true", "True", "1":
+		return systemStoreSource, ""
+	}
+	file = filepath.Clean(value)
+	for _, bundle := range systemCaBundles {
+		if sameCaFile(file, bundle) {
+			return fmt.Sprintf("%s (%s)", systemStoreSource, file), ""
+		}
+	}
+	return file, file
+}
+
+func micromambaCaSource() (source, file string) {
+	if !settings.Global.VerifySsl() {
+		return noVerifySource, ""
+	}
+	value, ok := micromambaRcSslVerify()
+	if ok {
+		source, file = caFileSource(value)
+		return fmt.Sprintf("%s [micromambarc ssl_verify]", source), file
+	}
+	for _, key := range []string{"MAMBA_SSL_VERIFY", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE", "SSL_CERT_FILE"} {
+		value = os.Getenv(key)
+		if len(value) > 0 {
+			source, file = caFileSource(value)
+			return fmt.Sprintf("%s [%s]", source, key), file
+		}
+	}
+	return systemStoreSource, ""
+}
+
+func rccCaSource() (source, file string) {
+	if !settings.Global.VerifySsl() {
+		return noVerifySource, ""
+	}
+	if settings.Global.HasCaBundle() {
+		return fmt.Sprintf("%s + %s", systemStoreSource, common.CaBundleFile()), common.CaBundleFile()
+	}
+	return systemStoreSource, ""
+}
+
+func caSourceCheck(details map[string]string) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	rccSource, rccFile := rccCaSource()
+	mambaSource, mambaFile := micromambaCaSource()
+	details["ca-source-rcc"] = rccSource
+	details["ca-source-micromamba"] = mambaSource
+	diverged := false
+	switch {
+	case rccSource == noVerifySource || mambaSource == noVerifySource:
+		diverged = rccSource != mambaSource
+	case len(rccFile) > 0 || len(mambaFile) > 0:
+		diverged = !sameCaFile(rccFile, mambaFile)
+	}
+	if diverged {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkCaSources,
+			Code:     common.CodeCaSourcesDiffer,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("rcc trusts %q, but micromamba trusts %q. TLS may work for one and fail for the other.", rccSource, mambaSource),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkCaSources,
+		Code:     common.CodeCaSourcesConsistent,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("rcc and micromamba use consistent trust: %q vs. %q.", rccSource, mambaSource),
+		Link:     supportNetworkUrl,
+	}
+}
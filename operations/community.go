@@ -47,7 +47,7 @@ func CommunityLocation(name, branch string) string {
 }
 
 func DownloadCommunityRobot(url, filename string) error {
-	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	client := &http.Client{Transport: settings.Global.SharedHttpTransport()}
 	response, err := client.Get(url)
 	if err != nil {
 		return err
@@ -0,0 +1,25 @@
+package operations
+
+import (
+	"strings"
+)
+
+func windowsPathKey(location string) string {
+	return strings.TrimRight(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(location), "/", `\`)), `\`)
+}
+
+// excludedLocation tells if location is same as or below one of exclusion
+// paths, compared case-insensitively like windows filesystems do.
+func excludedLocation(location string, exclusions []string) (string, bool) {
+	target := windowsPathKey(location)
+	for _, exclusion := range exclusions {
+		candidate := windowsPathKey(exclusion)
+		if len(candidate) == 0 {
+			continue
+		}
+		if target == candidate || strings.HasPrefix(target, candidate+`\`) {
+			return strings.TrimSpace(exclusion), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,22 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestExcludedLocationMatchesWindowsPaths(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	exclusions := []string{`C:\Tools\`, ` c:\users\me\AppData\Local\robocorp `}
+	found, ok := excludedLocation(`C:\Users\Me\AppData\Local\robocorp`, exclusions)
+	must_be.True(ok)
+	must_be.Equal(`c:\users\me\AppData\Local\robocorp`, found)
+	_, ok = excludedLocation(`C:/Tools/robocorp`, exclusions)
+	must_be.True(ok)
+	_, ok = excludedLocation(`C:\ToolsAndMore\robocorp`, exclusions)
+	wont_be.True(ok)
+	_, ok = excludedLocation(`C:\robocorp`, nil)
+	wont_be.True(ok)
+}
@@ -0,0 +1,82 @@
+package operations
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/pretty"
+	"github.com/robocorp/rcc/set"
+)
+
+const (
+	diagnosticsHtml = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>rcc diagnostics {{.Version}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f4f4f4; }
+td.key { white-space: nowrap; font-family: monospace; }
+td.value { font-family: monospace; word-break: break-all; }
+tr.ok td.status { background: #dff0d8; color: #2b542c; }
+tr.warning td.status { background: #fcf8e3; color: #8a6d3b; }
+tr.fail td.status, tr.fatal td.status { background: #f2dede; color: #a94442; }
+.summary span { margin-right: 1em; font-weight: bold; }
+.readiness { padding: 0.6em; border-radius: 4px; }
+</style>
+</head>
+<body>
+<h1>rcc diagnostics</h1>
+<p>Generated by rcc {{.Version}} at {{.When}}.</p>
+{{with .Status.Readiness}}<p class="readiness {{.Status}}">Readiness: <b>{{.Status}}</b> {{.Message}}</p>{{end}}
+<p class="summary">{{range $status, $count := .Summary.Statuses}}<span>{{$count}} {{$status}}</span>{{end}}</p>
+<h2>Checks</h2>
+<table>
+<tr><th>Type</th><th>Status</th><th>Message</th><th>Help</th></tr>
+{{range .Status.Checks}}<tr class="{{.Status}}"><td>{{.Type}}</td><td class="status">{{.Status}}</td><td>{{.Message}}{{if .Command}}<br><code>{{.Command}}</code>{{end}}</td><td>{{if .Link}}<a href="{{.Link}}">{{.Link}}</a>{{end}}</td></tr>
+{{end}}</table>
+{{if .Status.NextSteps}}<h2>Next steps</h2>
+<ol>
+{{range .Status.NextSteps}}<li>{{.}}</li>
+{{end}}</ol>
+{{end}}<h2>Details</h2>
+<table>
+<tr><th>Key</th><th>Value</th></tr>
+{{range .Keys}}<tr><td class="key">{{.}}</td><td class="value">{{index $.Status.Details .}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+)
+
+type htmlReport struct {
+	Version string
+	When    string
+	Status  *common.DiagnosticStatus
+	Summary *common.DiagnosticSummary
+	Keys    []string
+}
+
+func htmlDiagnostics(sink io.Writer, details *common.DiagnosticStatus) {
+	report, err := template.New("diagnostics").Parse(diagnosticsHtml)
+	if err != nil {
+		pretty.Exit(1, "Error: %s", err)
+	}
+	err = report.Execute(sink, &htmlReport{
+		Version: common.Version,
+		When:    time.Now().Format(time.RFC3339),
+		Status:  details,
+		Summary: details.Summarize(),
+		Keys:    set.Keys(details.Details),
+	})
+	if err != nil {
+		pretty.Exit(1, "Error: %s", err)
+	}
+}
@@ -0,0 +1,29 @@
+package operations
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanRenderDiagnosticsAsHtml(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	status := &common.DiagnosticStatus{
+		Details: map[string]string{"hostname": "<box>"},
+		Checks: []*common.DiagnosticCheck{
+			{Type: "network", Status: statusFail, Message: "DNS failed", Link: "https://robocorp.com/docs/troubleshooting"},
+		},
+	}
+	sink := bytes.NewBuffer(nil)
+	htmlDiagnostics(sink, status)
+	body := sink.String()
+	must_be.True(strings.HasPrefix(body, "<!DOCTYPE html>"))
+	must_be.True(strings.Contains(body, `<tr class="fail">`))
+	must_be.True(strings.Contains(body, `<a href="https://robocorp.com/docs/troubleshooting">`))
+	must_be.True(strings.Contains(body, "&lt;box&gt;"))
+	wont_be.True(strings.Contains(body, "<box>"))
+}
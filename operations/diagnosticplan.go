@@ -0,0 +1,599 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	diagnosticWorkers = 8
+)
+
+type (
+	diagnosticProbe func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck
+	diagnosticGuard func(*DiagnosticsOptions) bool
+
+	diagnosticEntry struct {
+		Name     string `json:"name"`
+		Kind     string `json:"type"`
+		Category uint64 `json:"category"`
+		Slow     bool   `json:"slow"`
+		enabled  diagnosticGuard
+		probe    diagnosticProbe
+	}
+)
+
+// concurrently runs work for indexes 0..count-1 with bounded parallelism,
+// callers store results by index to keep deterministic order
+func concurrently(count int, work func(int)) {
+	limiter := make(chan bool, diagnosticWorkers)
+	waiter := sync.WaitGroup{}
+	for at := 0; at < count; at++ {
+		waiter.Add(1)
+		limiter <- true
+		go func(at int) {
+			defer func() {
+				<-limiter
+				waiter.Done()
+			}()
+			work(at)
+		}(at)
+	}
+	waiter.Wait()
+}
+
+func just(checks ...*common.DiagnosticCheck) []*common.DiagnosticCheck {
+	result := make([]*common.DiagnosticCheck, 0, len(checks))
+	for _, check := range checks {
+		if check != nil {
+			result = append(result, check)
+		}
+	}
+	return result
+}
+
+func always(*DiagnosticsOptions) bool {
+	return true
+}
+
+func pathVariableChecks() []*common.DiagnosticCheck {
+	keys := []string{
+		"CURL_CA_BUNDLE",
+		"NODE_EXTRA_CA_CERTS",
+		"NODE_OPTIONS",
+		"NODE_PATH",
+		"NODE_TLS_REJECT_UNAUTHORIZED",
+		"PIP_CONFIG_FILE",
+		"PLAYWRIGHT_BROWSERS_PATH",
+		"PYTHONPATH",
+		"REQUESTS_CA_BUNDLE",
+		"SSL_CERT_DIR",
+		"SSL_CERT_FILE",
+		"WDM_SSL_VERIFY",
+	}
+	result := make([]*common.DiagnosticCheck, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, anyPathCheck(key))
+	}
+	return result
+}
+
+func envVariableChecks() []*common.DiagnosticCheck {
+	return just(
+		anyEnvVarCheck("RCC_NO_TEMP_MANAGEMENT"),
+		anyEnvVarCheck("RCC_NO_PYC_MANAGEMENT"),
+		anyEnvVarCheck("ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS"),
+	)
+}
+
+func sharedHolotreeChecks() []*common.DiagnosticCheck {
+	locations := []string{
+		common.HoloLocation(),
+		common.HololibLocation(),
+		common.HololibCatalogLocation(),
+		common.HololibLibraryLocation(),
+	}
+	result := make([]*common.DiagnosticCheck, 0, len(locations))
+	for _, location := range locations {
+		result = append(result, verifySharedDirectory(location))
+	}
+	return append(result, sharedPermissionsChecks(locations)...)
+}
+
+func dnsLookupChecks(options *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+	hostnames := options.hostnames()
+	timeout := options.timeout()
+	checks := make([]*common.DiagnosticCheck, len(hostnames))
+	dnsStopwatch := common.Stopwatch("DNS lookup time for %d hostnames was about", len(hostnames))
+	concurrently(len(hostnames), func(at int) {
+		checks[at] = dnsLookupCheck(hostnames[at], options.DnsServer, timeout)
+	})
+	result.Details["dns-lookup-time"] = dnsStopwatch.Text()
+	return checks
+}
+
+func dnsFamilyChecks(options *DiagnosticsOptions) []*common.DiagnosticCheck {
+	hostnames := options.hostnames()
+	timeout := options.timeout()
+	checks := make([]*common.DiagnosticCheck, len(hostnames))
+	concurrently(len(hostnames), func(at int) {
+		checks[at] = dnsFamilyCheck(hostnames[at], timeout)
+	})
+	return checks
+}
+
+func httpReachabilityChecks(options *DiagnosticsOptions) []*common.DiagnosticCheck {
+	hostnames := options.hostnames()
+	timeout := options.timeout()
+	checks := make([]*common.DiagnosticCheck, len(hostnames))
+	concurrently(len(hostnames), func(at int) {
+		checks[at] = httpReachabilityCheck(hostnames[at], timeout)
+	})
+	return checks
+}
+
+func tlsHostChecks(options *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+	hostnames := options.hostnames()
+	tlsStopwatch := common.Stopwatch("TLS verification time for %d hostnames was about", len(hostnames))
+	partial := make([][]*common.DiagnosticCheck, len(hostnames))
+	roots := make([]map[string]bool, len(hostnames))
+	concurrently(len(hostnames), func(at int) {
+		roots[at] = make(map[string]bool)
+		partial[at] = tlsCheckHost(hostnames[at], roots[at])
+	})
+	checks := []*common.DiagnosticCheck{}
+	tlsRoots := make(map[string]bool)
+	for at := range hostnames {
+		checks = append(checks, partial[at]...)
+		for name, verified := range roots[at] {
+			tlsRoots[name] = verified
+		}
+	}
+	result.Details["tls-lookup-time"] = tlsStopwatch.Text()
+	if len(hostnames) > 1 && len(tlsRoots) == 1 {
+		for name, _ := range tlsRoots {
+			result.Details["tls-proxy-firewall"] = name
+		}
+	} else {
+		result.Details["tls-proxy-firewall"] = "undetectable"
+	}
+	return append(checks, just(tlsIssuersCheck(tlsRoots))...)
+}
+
+func diagnosticEntries() []*diagnosticEntry {
+	return []*diagnosticEntry{
+		{"shared-holotree", "OS", common.CategoryHolotreeShared, false,
+			func(*DiagnosticsOptions) bool { return common.SharedHolotree },
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return sharedHolotreeChecks()
+			}},
+		{"holotree-spaces", "RPA", common.CategoryHolotreeSpaces, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				_, roots := htfs.LoadCatalogs()
+				return just(holotreeSpacesCheck(roots.Spaces().BlueprintCounts(), result.Details))
+			}},
+		{"robocorp-home", "RPA", common.CategoryRobocorpHome, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(robocorpHomeCheck())
+			}},
+		{"robocorp-home-exec", "RPA", common.CategoryRobocorpHomeExec, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(robocorpHomeExecCheck(result.Details))
+			}},
+		{"robocorp-home-network", "RPA", common.CategoryRobocorpHomeNetwork, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(robocorpHomeNetworkCheck())
+			}},
+		{"home-variable", "OS", common.CategoryHomeVariable, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(homeVariableCheck(), homeConflictCheck(homeVariable, homeAlternative))
+			}},
+		{"memory-swap", "OS", common.CategoryMemorySwap, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(memorySwapCheck(result.Details))
+			}},
+		{"entropy", "OS", common.CategoryEntropy, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				entropy, err := hostEntropy()
+				return just(entropyCheck(result.Details, entropy, err))
+			}},
+		{"cpu-affinity", "OS", common.CategoryCpuAffinity, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(cpuAffinityCheck(result.Details))
+			}},
+		{"time-zone", "OS", common.CategoryTimeZone, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(timeZoneCheck(result.Details, time.Now(), time.LoadLocation))
+			}},
+		{"certificate-store", "OS", common.CategoryCertificateStore, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(certificateStoreCheck())
+			}},
+		{"ca-sources", "network", common.CategoryNetworkCaSources, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(caSourceCheck(result.Details))
+			}},
+		{"temp-directory", "OS", common.CategoryTempDirectory, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return tempDirectoryChecks(result.Details)
+			}},
+		{"micromamba-version", "RPA", common.CategoryMicromambaVersion, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(micromambaVersionCheck(result.Details))
+			}},
+		{"virtual-packages", "RPA", common.CategoryVirtualPackages, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(virtualPackagesCheck(result.Details))
+			}},
+		{"build-tools", "RPA", common.CategoryBuildTools, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(buildToolsCheck(result.Details))
+			}},
+		{"robocorp-home-names", "RPA", common.CategoryRobocorpHomeNames, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(robocorpHomeNamesCheck())
+			}},
+		{"disk-space", "OS", common.CategoryDiskSpace, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return diskSpaceChecks(result.Details)
+			}},
+		{"case-sensitivity", "OS", common.CategoryCaseSensitivity, true, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(caseSensitivityCheck(result.Details))
+			}},
+		{"config-permissions", "OS", common.CategoryConfigPermissions, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return configPermissionsCheck()
+			}},
+		{"robocorp-home-members", "RPA", common.CategoryRobocorpHomeMembers, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(robocorpHomeMemberCheck())
+			}},
+		{"micromamba-integrity", "RPA", common.CategoryMicromambaIntegrity, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(micromambaIntegrityCheck())
+			}},
+		{"working-directory", "RPA", common.CategoryPathCheck, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(workdirCheck())
+			}},
+		{"path-variables", "OS", common.CategoryPathCheck, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return pathVariableChecks()
+			}},
+		{"env-variables", "OS", common.CategoryEnvVarCheck, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return envVariableChecks()
+			}},
+		{"env-pollution", "OS", common.CategoryEnvPollution, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(environmentPollutionCheck())
+			}},
+		{"defender-exclusion", "OS", common.CategoryAntivirus, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return defenderExclusionCheck()
+			}},
+		{"rcc-on-path", "OS", common.CategoryRccOnPath, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(rccOnPathCheck())
+			}},
+		{"wsl", "OS", common.CategoryWsl, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return wslChecks(result.Details, wslKernel(), common.RobocorpHome())
+			}},
+		{"rcc-location", "OS", common.CategoryRccLocation, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				self := common.BinRcc()
+				return just(rccLocationCheck(self, temporaryDirectories(), quarantined(self)))
+			}},
+		{"local-listener", "OS", common.CategoryLocalListener, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(localListenerCheck())
+			}},
+		{"long-path", "OS", common.CategoryLongPath, false,
+			func(*DiagnosticsOptions) bool { return !common.OverrideSystemRequirements() },
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(longPathSupportCheck())
+			}},
+		{"lock-pids", "OS", common.CategoryLockPid, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return lockpidsCheck()
+			}},
+		{"lock-stale", "OS", common.CategoryLockStale, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				// must run before lock-files, which rewrites some of these
+				return staleLocksCheck(common.HolotreeLocation(), staleLockLimit, pathlib.LockHoldersBy)
+			}},
+		{"lock-files", "OS", common.CategoryLockFile, false, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return lockfilesCheck()
+			}},
+		{"proxy-routing", "network", common.CategoryNetworkProxyRouting, false, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return proxyRoutingChecks(options.hostnames())
+			}},
+		{"io-benchmark", "OS", common.CategoryHolotreeBenchmark, false,
+			func(options *DiagnosticsOptions) bool { return options.IoBenchmark },
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(ioBenchmarkCheck(result.Details))
+			}},
+		{"environment-benchmark", "RPA", common.CategoryEnvironmentBenchmark, false,
+			func(options *DiagnosticsOptions) bool { return options.Benchmark && !options.Offline },
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				// not slow, since slow checks run in parallel, and this
+				// temporarily changes ROBOCORP_HOME
+				return just(environmentBenchmarkCheck(result.Details))
+			}},
+		{"dns-lookup", "network", common.CategoryNetworkDNS, true, always,
+			func(options *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return dnsLookupChecks(options, result)
+			}},
+		{"hosts-file", "network", common.CategoryNetworkHostsFile, false, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return hostsFileChecks(hostsFile(), options.hostnames())
+			}},
+		{"dns-families", "network", common.CategoryNetworkDNSFamily, true, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return dnsFamilyChecks(options)
+			}},
+		{"dns-ttl", "network", common.CategoryNetworkDNSTTL, true,
+			func(options *DiagnosticsOptions) bool { return options.DnsTTL },
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(dnsTtlCheck(options.DnsServer, dnsTtlSampleGap, options.timeout()))
+			}},
+		{"tls-hosts", "network", common.CategoryNetworkTLSVersion, true, always,
+			func(options *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return tlsHostChecks(options, result)
+			}},
+		{"http-reachability", "network", common.CategoryNetworkReachability, true, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return httpReachabilityChecks(options)
+			}},
+		{"tls-roots", "network", common.CategoryNetworkTLSRoots, false, always,
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(tlsRootStoreCheck(result.Details))
+			}},
+		{"tls-minimum", "network", common.CategoryNetworkTLSMinimum, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(tlsMinimumCheck())
+			}},
+		{"proxy-tunnel", "network", common.CategoryNetworkProxyTunnel, true, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(proxyCheck(options.timeout()))
+			}},
+		{"canary-download", "network", common.CategoryNetworkCanary, true, always,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return canaryDownloadCheck(options.timeout())
+			}},
+		{"canary-ipv6", "network", common.CategoryNetworkCanaryIPv6, true, ipv6Possible,
+			func(options *DiagnosticsOptions, _ *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				transport := settings.Global.ConfiguredHttpTransport()
+				return just(canaryIpv6Check(settings.Global.CanaryURL(), settings.Global.CanaryContent(), transport, options.timeout(), ipv6Required()))
+			}},
+		{"large-payload", "network", common.CategoryNetworkLargePayload, true,
+			func(options *DiagnosticsOptions) bool { return options.Payload },
+			func(_ *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return mtuPathChecks(result.Details)
+			}},
+		{"range-request", "network", common.CategoryNetworkRange, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(rangeRequestCheck())
+			}},
+		{"upload", "network", common.CategoryNetworkUpload, true,
+			func(options *DiagnosticsOptions) bool { return options.UploadCheck },
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(uploadCheck())
+			}},
+		{"pypi-head", "network", common.CategoryNetworkHEAD, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(pypiHeadCheck())
+			}},
+		{"conda-head", "network", common.CategoryNetworkHEAD, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(condaHeadCheck())
+			}},
+		{"telemetry", "network", common.CategoryNetworkTelemetry, true, always,
+			func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+				return just(telemetryCheck())
+			}},
+	}
+}
+
+// selectedBy tells if selector names this entry, either by its name, name
+// prefix (like "tls" for "tls-hosts"), kind (like "network") or category
+func (it *diagnosticEntry) selectedBy(selector string) bool {
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	switch {
+	case selector == it.Name:
+		return true
+	case strings.HasPrefix(it.Name, selector+"-"):
+		return true
+	case selector == strings.ToLower(it.Kind):
+		return true
+	}
+	return selector == fmt.Sprintf("%d", it.Category)
+}
+
+func (it *diagnosticEntry) selectedByAny(selectors []string) bool {
+	for _, selector := range selectors {
+		if it.selectedBy(selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSelectors errors on selectors which do not match any check, so
+// that typos do not silently run (or skip) something else
+func validateSelectors(selectors []string) error {
+	entries := diagnosticEntries()
+	unknown := []string{}
+search:
+	for _, selector := range selectors {
+		for _, entry := range entries {
+			if entry.selectedBy(selector) {
+				continue search
+			}
+		}
+		unknown = append(unknown, selector)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("Unknown diagnostics check selector(s): %s. Use check names, name prefixes (like tls), kinds (OS, RPA, network) or category codes; see --dryrun for list.", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func plannedChecks(options *DiagnosticsOptions) []*diagnosticEntry {
+	result := []*diagnosticEntry{}
+	for _, entry := range diagnosticEntries() {
+		if len(options.Only) > 0 && !entry.selectedByAny(options.Only) {
+			continue
+		}
+		if entry.selectedByAny(options.Skip) {
+			continue
+		}
+		if entry.Slow && options.Quick {
+			continue
+		}
+		if entry.Kind == "network" && options.Offline {
+			continue
+		}
+		if !entry.enabled(options) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+type checkReporter func([]*common.DiagnosticCheck) []*common.DiagnosticCheck
+
+// reporter passes checks to OnCheck callback as soon as each entry has
+// finished, serialized since slow entries finish concurrently
+func (it *DiagnosticsOptions) reporter(result *common.DiagnosticStatus) checkReporter {
+	if it.OnCheck == nil {
+		return func(checks []*common.DiagnosticCheck) []*common.DiagnosticCheck {
+			return checks
+		}
+	}
+	var anonymizer *strings.Replacer
+	if it.Anonymize {
+		anonymizer = result.Anonymizer(identifyingDetails)
+	}
+	var lock sync.Mutex
+	return func(checks []*common.DiagnosticCheck) []*common.DiagnosticCheck {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, check := range checks {
+			if check == nil {
+				continue
+			}
+			if anonymizer != nil {
+				check.Message = anonymizer.Replace(check.Message)
+			}
+			it.OnCheck(check)
+		}
+		return checks
+	}
+}
+
+// suppressed turns non-ok results of entry into ok ones, when settings
+// policy has accepted problems behind it; original status and message stay
+// visible in note, so that suppression is not silent
+func (it *diagnosticEntry) suppressed(selectors []string, checks []*common.DiagnosticCheck) []*common.DiagnosticCheck {
+	if !it.selectedByAny(selectors) {
+		return checks
+	}
+	for _, check := range checks {
+		if check.Status == statusOk {
+			continue
+		}
+		check.Message = fmt.Sprintf("Suppressed by policy (was %s): %s", check.Status, check.Message)
+		check.Status = statusOk
+		check.Command = ""
+	}
+	return checks
+}
+
+// run executes probe of entry, and with debug flag logs start and finish
+// of it, so that stuck checks can be seen while diagnostics is running
+func (it *diagnosticEntry) run(options *DiagnosticsOptions, result *common.DiagnosticStatus) []*common.DiagnosticCheck {
+	suppress := settings.Global.SuppressedChecks()
+	if !common.DebugFlag() {
+		return it.suppressed(suppress, it.probe(options, result))
+	}
+	common.Debug("Diagnostics check %q [%s/%d] started.", it.Name, it.Kind, it.Category)
+	started := time.Now()
+	checks := it.probe(options, result)
+	common.Debug("Diagnostics check %q finished in %s with %d results.", it.Name, time.Since(started).Round(time.Millisecond), len(checks))
+	return it.suppressed(suppress, checks)
+}
+
+func runPlan(options *DiagnosticsOptions, result *common.DiagnosticStatus, plan []*diagnosticEntry, report checkReporter) {
+	slow := []*diagnosticEntry{}
+	for _, entry := range plan {
+		if entry.Slow {
+			slow = append(slow, entry)
+			continue
+		}
+		result.Checks = append(result.Checks, report(entry.run(options, result))...)
+	}
+	partial := make([]*common.DiagnosticStatus, len(slow))
+	concurrently(len(slow), func(at int) {
+		scratch := &common.DiagnosticStatus{Details: make(map[string]string)}
+		scratch.Checks = report(slow[at].run(options, scratch))
+		partial[at] = scratch
+	})
+	for _, scratch := range partial {
+		result.Checks = append(result.Checks, scratch.Checks...)
+		for key, value := range scratch.Details {
+			result.Details[key] = value
+		}
+	}
+}
+
+// plannedCheck is dry-run view of entry, which also tells if settings
+// policy suppresses problems of it
+type plannedCheck struct {
+	*diagnosticEntry
+	Suppressed bool `json:"suppressed"`
+}
+
+func printPlan(sink io.Writer, plan []*diagnosticEntry, suppress []string, asJson bool) error {
+	planned := make([]*plannedCheck, 0, len(plan))
+	for _, entry := range plan {
+		planned = append(planned, &plannedCheck{entry, entry.selectedByAny(suppress)})
+	}
+	if asJson {
+		body, err := json.MarshalIndent(planned, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(sink, string(body))
+		return nil
+	}
+	fmt.Fprintf(sink, "Diagnostics would run %d checks:\n", len(plan))
+	tabbed := tabwriter.NewWriter(sink, 2, 4, 2, ' ', 0)
+	for _, entry := range planned {
+		speed := "quick"
+		if entry.Slow {
+			speed = "slow"
+		}
+		policy := ""
+		if entry.Suppressed {
+			policy = "suppressed"
+		}
+		fmt.Fprintf(tabbed, " - %s\t%s\t%d\t%s\t%s\n", entry.Name, entry.Kind, entry.Category, speed, policy)
+	}
+	return tabbed.Flush()
+}
@@ -0,0 +1,151 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestConcurrentWorkKeepsOrder(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	results := make([]int, 50)
+	concurrently(len(results), func(at int) {
+		results[at] = at * at
+	})
+	for at, value := range results {
+		must_be.Equal(at*at, value)
+	}
+	concurrently(0, func(int) {
+		t.Fatal("should not be called")
+	})
+}
+
+func TestQuickPlanHasNoSlowChecks(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	full := plannedChecks(&DiagnosticsOptions{})
+	quick := plannedChecks(&DiagnosticsOptions{Quick: true})
+	must_be.True(len(quick) < len(full))
+	for _, entry := range quick {
+		wont_be.True(entry.Slow)
+	}
+	spawning := []string{"micromamba-version", "virtual-packages", "build-tools", "rcc-on-path", "micromamba-integrity", "temp-directory", "robocorp-home-exec", "case-sensitivity"}
+	for _, entry := range quick {
+		wont_be.True(entry.selectedByAny(spawning))
+	}
+}
+
+func TestLargePayloadIsOptIn(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	for _, entry := range plannedChecks(&DiagnosticsOptions{}) {
+		wont_be.Equal("large-payload", entry.Name)
+	}
+	found := false
+	for _, entry := range plannedChecks(&DiagnosticsOptions{Payload: true}) {
+		found = found || entry.Name == "large-payload"
+	}
+	must_be.True(found)
+}
+
+func TestOfflinePlanHasNoNetworkChecks(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	offline := plannedChecks(&DiagnosticsOptions{Offline: true})
+	must_be.True(len(offline) > 0)
+	for _, entry := range offline {
+		wont_be.Equal("network", entry.Kind)
+	}
+
+	options := &DiagnosticsOptions{Hosts: []string{"mirror.example.com"}, Timeout: time.Second}
+	must_be.Equal([]string{"mirror.example.com"}, options.hostnames())
+	must_be.Equal(time.Second, options.timeout())
+	wont_be.Equal(0, len((&DiagnosticsOptions{}).hostnames()))
+}
+
+func TestSelectorsPickAndSkipChecks(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	only := plannedChecks(&DiagnosticsOptions{Only: []string{"tls", "dns-lookup"}})
+	must_be.True(len(only) > 1)
+	for _, entry := range only {
+		must_be.True(entry.Name == "dns-lookup" || strings.HasPrefix(entry.Name, "tls-"))
+	}
+
+	skipped := plannedChecks(&DiagnosticsOptions{Skip: []string{"Network"}})
+	must_be.True(len(skipped) > 0)
+	for _, entry := range skipped {
+		wont_be.Equal("network", entry.Kind)
+	}
+
+	must_be.Nil(validateSelectors([]string{"network", "tls", "dns-lookup", "OS"}))
+	wont_be.Nil(validateSelectors([]string{"tls", "nosuchcheck"}))
+	wont_be.Nil(validateSelectors([]string{"dn"}))
+}
+
+func TestRunPlanReportsEachCheckOnce(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	probe := func(message string) func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+		return func(*DiagnosticsOptions, *common.DiagnosticStatus) []*common.DiagnosticCheck {
+			return just(&common.DiagnosticCheck{Type: "OS", Status: statusOk, Message: message}, nil)
+		}
+	}
+	plan := []*diagnosticEntry{
+		{"first", "OS", 1, false, always, probe("id-1234 first")},
+		{"second", "OS", 2, true, always, probe("second")},
+		{"third", "OS", 3, true, always, probe("third")},
+	}
+	seen := []string{}
+	options := &DiagnosticsOptions{Anonymize: true}
+	options.OnCheck = func(check *common.DiagnosticCheck) {
+		seen = append(seen, check.Message)
+	}
+	result := &common.DiagnosticStatus{Details: map[string]string{"installationId": "id-1234"}}
+	runPlan(options, result, plan, options.reporter(result))
+	must_be.Equal(3, len(result.Checks))
+	must_be.Equal(3, len(seen))
+	must_be.Equal(common.Anonymized("id-1234")+" first", seen[0])
+}
+
+func TestSuppressedChecksAreReportedAsOk(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	entry := &diagnosticEntry{Name: "long-path", Kind: "OS", Category: common.CategoryLongPath}
+	checks := func() []*common.DiagnosticCheck {
+		return []*common.DiagnosticCheck{
+			{Status: statusFail, Message: "Long paths are not enabled.", Command: "fix it"},
+			{Status: statusOk, Message: "All good."},
+		}
+	}
+	untouched := entry.suppressed([]string{"tls", "network"}, checks())
+	must_be.Equal(statusFail, untouched[0].Status)
+	must_be.Equal("fix it", untouched[0].Command)
+
+	for _, selector := range []string{"long-path", "os", fmt.Sprintf("%d", common.CategoryLongPath)} {
+		suppressed := entry.suppressed([]string{selector}, checks())
+		must_be.Equal(statusOk, suppressed[0].Status)
+		must_be.Equal("Suppressed by policy (was fail): Long paths are not enabled.", suppressed[0].Message)
+		must_be.Equal("", suppressed[0].Command)
+		must_be.Equal("All good.", suppressed[1].Message)
+	}
+
+	plan := []*diagnosticEntry{entry, {Name: "dns-lookup", Kind: "network", Category: common.CategoryNetworkDNS}}
+	sink := &bytes.Buffer{}
+	must_be.Nil(printPlan(sink, plan, []string{"long-path"}, true))
+	must_be.True(strings.Contains(sink.String(), `"suppressed": true`))
+	must_be.True(strings.Contains(sink.String(), `"suppressed": false`))
+	must_be.True(strings.Contains(sink.String(), `"name": "long-path"`))
+
+	sink.Reset()
+	must_be.Nil(printPlan(sink, plan, []string{"long-path"}, false))
+	lines := strings.Split(sink.String(), "\n")
+	must_be.True(strings.HasSuffix(strings.TrimSpace(lines[1]), "suppressed"))
+	must_be.True(strings.HasSuffix(strings.TrimSpace(lines[2]), "slow") || strings.HasSuffix(strings.TrimSpace(lines[2]), "quick"))
+}
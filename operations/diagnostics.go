@@ -1,10 +1,16 @@
 package operations
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -12,7 +18,9 @@ import (
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/robocorp/rcc/blobs"
 	"github.com/robocorp/rcc/cloud"
 	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/conda"
@@ -21,23 +29,106 @@ import (
 	"github.com/robocorp/rcc/pathlib"
 	"github.com/robocorp/rcc/pretty"
 	"github.com/robocorp/rcc/robot"
+	"github.com/robocorp/rcc/set"
 	"github.com/robocorp/rcc/settings"
 	"github.com/robocorp/rcc/xviper"
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	canaryUrl      = `/canary.txt`
-	pypiCanaryUrl  = `/jupyterlab-pygments/`
-	condaCanaryUrl = `/conda-forge/linux-64/repodata.json`
-	statusOk       = `ok`
-	statusWarning  = `warning`
-	statusFail     = `fail`
-	statusFatal    = `fatal`
+	canaryAttempts   = 3
+	pypiCanaryUrl    = `/jupyterlab-pygments/`
+	condaCanaryUrl   = `/conda-forge/linux-64/repodata.json`
+	statusOk         = `ok`
+	statusWarning    = `warning`
+	statusFail       = `fail`
+	statusFatal      = `fatal`
+	syslogTag        = `rcc`
+	lowMemoryLimit   = 8 * 1024 * 1024 * 1024
+	clockSkewWarning = 60 * time.Second
+	clockSkewFail    = 5 * time.Minute
+	staleLockLimit   = 1 * time.Hour
+	lowEntropyLimit  = 256
+	tzdataProbeZone  = `America/New_York`
+	slowLatencyLimit = 2 * time.Second
+	formatText       = `text`
+	formatJson       = `json`
+	formatYaml       = `yaml`
+	formatHtml       = `html`
+	noRecords        = `no records`
+	utf8Bom          = "\ufeff"
 )
 
+// entropyStatus is kernel entropy pool state, where kernels having modern
+// CRNG (5.6 and newer) do not block on low entropy after boot
+type entropyStatus struct {
+	Available int
+	Kernel    string
+}
+
+func (it *entropyStatus) modernCrng() bool {
+	var major, minor int
+	_, err := fmt.Sscanf(it.Kernel, "%d.%d", &major, &minor)
+	return err == nil && (major > 5 || (major == 5 && minor >= 6))
+}
+
+type memoryStatus struct {
+	Total     uint64
+	Available uint64
+	SwapTotal uint64
+	SwapFree  uint64
+	SwapLabel string
+	NoSwap    bool
+}
+
+type DiagnosticsOptions struct {
+	Quick       bool
+	DnsTTL      bool
+	IoBenchmark bool
+	Benchmark   bool
+	UploadCheck bool
+	Payload     bool
+	DryRun      bool
+	Save        bool
+	OmitDetails []string
+	Anonymize   bool
+	Categories  []string
+	TlsHosts    []string
+	Append      bool
+	Hosts       []string
+	Timeout     time.Duration
+	Offline     bool
+	Stream      bool
+	JsonFile    string
+	Only        []string
+	Skip        []string
+	DnsServer   string
+	Syslog      bool
+	ConnectTo   string
+	FailuresAt  string
+	SNI         string
+	OnCheck     func(*common.DiagnosticCheck)
+}
+
 var (
+	virtualPackageMinimums = map[string]string{
+		"__glibc": "2.17",
+		"__osx":   "10.13",
+	}
 	ignorePathContains = []string{".vscode", ".ipynb_checkpoints", ".virtual_documents"}
+	// home directory goes before paths under it, so that those keep their
+	// relative part visible after anonymization
+	identifyingDetails = []string{"installationId", "controller", "holotree-user-id", "machine-fingerprint", "user-home-dir", "user-cache-dir", "user-config-dir", "working-dir", "hostname", "user-name", "user-username"}
+	readinessItems     = map[uint64]string{
+		common.CategoryLongPath:         "long path support",
+		common.CategoryLockFile:         "writable ROBOCORP_HOME",
+		common.CategoryRobocorpHome:     "valid ROBOCORP_HOME",
+		common.CategoryRobocorpHomeExec: "executable ROBOCORP_HOME",
+		common.CategoryCertificateStore: "CA certificate store",
+		common.CategoryNetworkDNS:       "DNS resolution of key hosts",
+		common.CategoryDiskSpace:        "free disk space",
+		common.CategoryTempDirectory:    "writable temporary directory",
+	}
 )
 
 func shouldIgnorePath(fullpath string) bool {
@@ -57,7 +148,71 @@ func justText(source stringerr) string {
 	return result
 }
 
-func runDiagnostics(quick bool) *common.DiagnosticStatus {
+func tlsHostDiagnostics(hosts []string, route *tlsRoute) *common.DiagnosticStatus {
+	result := &common.DiagnosticStatus{
+		Details: make(map[string]string),
+		Checks:  []*common.DiagnosticCheck{},
+	}
+	result.Details["rcc"] = common.Version
+	result.Details["tls-hosts"] = strings.Join(hosts, ", ")
+	result.Details["when"] = time.Now().Format(time.RFC3339 + " (MST)")
+	tlsStopwatch := common.Stopwatch("TLS verification time for %d hostnames was about", len(hosts))
+	if route != nil {
+		result.Details["tls-connect-to"] = route.connect
+		result.Details["tls-sni"] = route.sni
+	}
+	result.Checks = checkTLSVia(hosts, route)
+	result.Details["tls-lookup-time"] = tlsStopwatch.Text()
+	return result
+}
+
+func (it *DiagnosticsOptions) hostnames() []string {
+	if len(it.Hosts) > 0 {
+		return it.Hosts
+	}
+	return settings.Global.Hostnames()
+}
+
+// tlsRoute is nil, unless connect address or SNI override is given
+func (it *DiagnosticsOptions) tlsRoute() *tlsRoute {
+	if len(it.ConnectTo) == 0 && len(it.SNI) == 0 {
+		return nil
+	}
+	return &tlsRoute{connect: it.ConnectTo, sni: it.SNI}
+}
+
+// tlsHosts are explicitly requested TLS hosts, defaulting to SNI name
+func (it *DiagnosticsOptions) tlsHosts() []string {
+	if len(it.TlsHosts) == 0 && len(it.SNI) > 0 {
+		return []string{it.SNI}
+	}
+	return it.TlsHosts
+}
+
+func (it *DiagnosticsOptions) timeout() time.Duration {
+	if it.Timeout > 0 {
+		return it.Timeout
+	}
+	return settings.Global.DiagnosticsTimeout()
+}
+
+// RunDiagnosticsWith is library entry point, which runs checks against
+// given hosts (or configured ones, if empty) and returns structured results
+// without any output. Offline option skips all network checks.
+func RunDiagnosticsWith(hosts []string, options DiagnosticsOptions) *common.DiagnosticStatus {
+	if len(hosts) > 0 {
+		options.Hosts = hosts
+	}
+	result := RunDiagnostics(&options)
+	settings.Global.Diagnostics(result)
+	result.Readiness = readinessCheck(result.Checks)
+	result.NextSteps = result.Remediations()
+	return result
+}
+
+func RunDiagnostics(options *DiagnosticsOptions) *common.DiagnosticStatus {
+	// time.Now carries monotonic clock, so wall clock jumps do not matter
+	started := time.Now()
 	result := &common.DiagnosticStatus{
 		Details: make(map[string]string),
 		Checks:  []*common.DiagnosticCheck{},
@@ -83,6 +238,7 @@ func runDiagnostics(quick bool) *common.DiagnosticStatus {
 	result.Details["controller"] = common.ControllerIdentity()
 	result.Details["user-agent"] = common.UserAgent()
 	result.Details["installationId"] = xviper.TrackingIdentity()
+	result.Details["machine-fingerprint"] = machineFingerprint()
 	result.Details["telemetry-enabled"] = fmt.Sprintf("%v", xviper.CanTrack())
 	result.Details["config-piprc-used"] = fmt.Sprintf("%v", settings.Global.HasPipRc())
 	result.Details["config-micromambarc-used"] = fmt.Sprintf("%v", settings.Global.HasMicroMambaRc())
@@ -92,6 +248,11 @@ func runDiagnostics(quick bool) *common.DiagnosticStatus {
 	result.Details["config-https-proxy"] = settings.Global.HttpsProxy()
 	result.Details["config-http-proxy"] = settings.Global.HttpProxy()
 	result.Details["config-no-proxy"] = settings.Global.NoProxy()
+	result.Details["config-ip-family"] = settings.Global.IpFamily()
+	result.Details["config-suppressed-checks"] = strings.Join(settings.Global.SuppressedChecks(), ", ")
+	result.Details["config-diagnostics-hosts"] = strings.Join(settings.Global.DiagnosticHosts(), ", ")
+	result.Details["diagnostics-hosts"] = strings.Join(options.hostnames(), ", ")
+	proxyEnvironmentDetails(result.Details)
 	result.Details["config-ssl-verify"] = fmt.Sprintf("%v", settings.Global.VerifySsl())
 	result.Details["config-ssl-no-revoke"] = fmt.Sprintf("%v", settings.Global.NoRevocation())
 	result.Details["config-legacy-renegotiation-allowed"] = fmt.Sprintf("%v", settings.Global.LegacyRenegotiation())
@@ -121,78 +282,81 @@ func runDiagnostics(quick bool) *common.DiagnosticStatus {
 		result.Details[name] = filename
 	}
 
+	for name, state := range featureFlags() {
+		result.Details[name] = state
+	}
+
 	who, err := user.Current()
 	if err == nil {
 		result.Details["uid:gid"] = fmt.Sprintf("%s:%s", who.Uid, who.Gid)
 	}
 
 	// checks
-	if common.SharedHolotree {
-		result.Checks = append(result.Checks, verifySharedDirectory(common.HoloLocation()))
-		result.Checks = append(result.Checks, verifySharedDirectory(common.HololibLocation()))
-		result.Checks = append(result.Checks, verifySharedDirectory(common.HololibCatalogLocation()))
-		result.Checks = append(result.Checks, verifySharedDirectory(common.HololibLibraryLocation()))
-	}
-	result.Checks = append(result.Checks, robocorpHomeCheck())
-	check := robocorpHomeMemberCheck()
-	if check != nil {
-		result.Checks = append(result.Checks, check)
-	}
-	check = workdirCheck()
-	if check != nil {
-		result.Checks = append(result.Checks, check)
-	}
-
-	result.Checks = append(result.Checks, anyPathCheck("CURL_CA_BUNDLE"))
-	result.Checks = append(result.Checks, anyPathCheck("NODE_EXTRA_CA_CERTS"))
-	result.Checks = append(result.Checks, anyPathCheck("NODE_OPTIONS"))
-	result.Checks = append(result.Checks, anyPathCheck("NODE_PATH"))
-	result.Checks = append(result.Checks, anyPathCheck("NODE_TLS_REJECT_UNAUTHORIZED"))
-	result.Checks = append(result.Checks, anyPathCheck("PIP_CONFIG_FILE"))
-	result.Checks = append(result.Checks, anyPathCheck("PLAYWRIGHT_BROWSERS_PATH"))
-	result.Checks = append(result.Checks, anyPathCheck("PYTHONPATH"))
-	result.Checks = append(result.Checks, anyPathCheck("REQUESTS_CA_BUNDLE"))
-	result.Checks = append(result.Checks, anyPathCheck("SSL_CERT_DIR"))
-	result.Checks = append(result.Checks, anyPathCheck("SSL_CERT_FILE"))
-	result.Checks = append(result.Checks, anyPathCheck("WDM_SSL_VERIFY"))
-
-	result.Checks = append(result.Checks, anyEnvVarCheck("RCC_NO_TEMP_MANAGEMENT"))
-	result.Checks = append(result.Checks, anyEnvVarCheck("RCC_NO_PYC_MANAGEMENT"))
-	result.Checks = append(result.Checks, anyEnvVarCheck("ROBOCORP_OVERRIDE_SYSTEM_REQUIREMENTS"))
-
-	if !common.OverrideSystemRequirements() {
-		result.Checks = append(result.Checks, longPathSupportCheck())
-	}
-	result.Checks = append(result.Checks, lockpidsCheck()...)
-	result.Checks = append(result.Checks, lockfilesCheck()...)
-	if quick {
-		return result
+	report := options.reporter(result)
+	runPlan(options, result, plannedChecks(options), report)
+	if options.Offline {
+		result.Checks = append(result.Checks, report(just(offlineCheck()))...)
 	}
-
-	// Move slow checks below this position
-
-	hostnames := settings.Global.Hostnames()
-	dnsStopwatch := common.Stopwatch("DNS lookup time for %d hostnames was about", len(hostnames))
-	for _, host := range hostnames {
-		result.Checks = append(result.Checks, dnsLookupCheck(host))
+	if options.Anonymize {
+		result.Anonymize(identifyingDetails)
 	}
-	result.Details["dns-lookup-time"] = dnsStopwatch.Text()
-	tlsStopwatch := common.Stopwatch("TLS verification time for %d hostnames was about", len(hostnames))
-	tlsRoots := make(map[string]bool)
-	for _, host := range hostnames {
-		result.Checks = append(result.Checks, tlsCheckHost(host, tlsRoots)...)
+	elapsed := time.Since(started)
+	result.DurationMs = elapsed.Milliseconds()
+	result.Details["diagnostics-duration"] = elapsed.Round(time.Millisecond).String()
+	return result
+}
+
+func offlineCheck() *common.DiagnosticCheck {
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkOffline,
+		Code:     common.CodeNetworkOffline,
+		Status:   statusOk,
+		Message:  "Network diagnostics were disabled [offline mode], so only local checks were run.",
+		Link:     settings.Global.DocsLink("troubleshooting/firewall-and-proxies"),
 	}
-	result.Details["tls-lookup-time"] = tlsStopwatch.Text()
-	if len(hostnames) > 1 && len(tlsRoots) == 1 {
-		for name, _ := range tlsRoots {
-			result.Details["tls-proxy-firewall"] = name
+}
+
+type toggleSource struct {
+	label  string
+	active bool
+}
+
+func toggleState(sources ...toggleSource) string {
+	active := []string{}
+	for _, source := range sources {
+		if source.active {
+			active = append(active, source.label)
 		}
-	} else {
-		result.Details["tls-proxy-firewall"] = "undetectable"
 	}
-	result.Checks = append(result.Checks, canaryDownloadCheck())
-	result.Checks = append(result.Checks, pypiHeadCheck())
-	result.Checks = append(result.Checks, condaHeadCheck())
+	if len(active) == 0 {
+		return "false"
+	}
+	return fmt.Sprintf("true [%s]", strings.Join(active, ", "))
+}
+
+func featureFlags() map[string]string {
+	verbosity := os.Getenv(common.RCC_VERBOSITY)
+	verboseEnv := verbosity == common.DEBUGGING || verbosity == common.TRACING
+	result := make(map[string]string)
+	result["flag:debug"] = toggleState(toggleSource{"env:RCC_VERBOSITY", verboseEnv}, toggleSource{"cli:--debug", common.DebugFlag() && !verboseEnv})
+	result["flag:trace"] = toggleState(toggleSource{"env:RCC_VERBOSITY", verbosity == common.TRACING}, toggleSource{"cli:--trace", common.TraceFlag() && verbosity != common.TRACING})
+	result["flag:no-build"] = toggleState(toggleSource{"cli:--no-build", common.NoBuild}, toggleSource{"env:RCC_NO_BUILD", len(os.Getenv("RCC_NO_BUILD")) > 0}, toggleSource{"settings:options/no-build", settings.Global.Option("no-build")})
+	result["flag:no-retry-build"] = toggleState(toggleSource{"cli:--no-retry-build", common.NoRetryBuild})
+	result["flag:strict"] = toggleState(toggleSource{"cli:--strict", common.StrictFlag})
+	result["flag:nocache"] = toggleState(toggleSource{"cli:--nocache", common.NoCache})
+	result["flag:liveonly"] = toggleState(toggleSource{"cli:--liveonly", common.Liveonly})
+	result["flag:timeline"] = toggleState(toggleSource{"cli:--timeline", common.TimelineEnabled})
+	result["flag:numbers"] = toggleState(toggleSource{"cli:--numbers", common.LogLinenumbers})
+	result["flag:unmanaged"] = toggleState(toggleSource{"cli:--unmanaged", common.UnmanagedSpace})
+	result["flag:externally-managed"] = toggleState(toggleSource{"cli:--externally-managed", common.ExternallyManaged})
+	result["flag:warranty-voided"] = toggleState(toggleSource{"cli:--warranty-voided", common.WarrantyVoided()})
+	result["flag:bundled"] = toggleState(toggleSource{"cli:--bundled", common.IsBundled()})
+	result["flag:no-temp-management"] = toggleState(toggleSource{"cli:--no-temp-management", common.NoTempManagement}, toggleSource{"env:RCC_NO_TEMP_MANAGEMENT", len(os.Getenv(common.RCC_NO_TEMP_MANAGEMENT)) > 0})
+	result["flag:no-pyc-management"] = toggleState(toggleSource{"cli:--no-pyc-management", common.NoPycManagement}, toggleSource{"env:RCC_NO_PYC_MANAGEMENT", len(os.Getenv(common.RCC_NO_PYC_MANAGEMENT)) > 0})
+	result["flag:verbose-environment-building"] = toggleState(toggleSource{"env:RCC_VERBOSE_ENVIRONMENT_BUILDING", len(os.Getenv(common.VERBOSE_ENVIRONMENT_BUILDING)) > 0}, toggleSource{"implied:debug", common.DebugFlag()})
+	result["flag:shared-holotree"] = toggleState(toggleSource{"file:" + common.HoloInitUserFile(), common.SharedHolotree})
+	result["flag:hololib-compression"] = toggleState(toggleSource{"default", htfs.Compress()})
 	return result
 }
 
@@ -212,6 +376,7 @@ func longPathSupportCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryLongPath,
+			Code:     common.CodeLongPathOk,
 			Status:   statusOk,
 			Message:  "Supports long enough paths.",
 			Link:     supportLongPathUrl,
@@ -220,9 +385,11 @@ func longPathSupportCheck() *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "OS",
 		Category: common.CategoryLongPath,
+		Code:     common.CodeLongPathMissing,
 		Status:   statusFail,
 		Message:  "Does not support long path names!",
 		Link:     supportLongPathUrl,
+		Command:  longPathCommand,
 	}
 }
 
@@ -243,6 +410,7 @@ func lockfilesCheck() []*common.DiagnosticCheck {
 			result = append(result, &common.DiagnosticCheck{
 				Type:     "OS",
 				Category: common.CategoryLockFile,
+				Code:     common.CodeLockFileWriteFailed,
 				Status:   statusFail,
 				Message:  fmt.Sprintf("Lock file %q write failed, reason: %v", identity, err),
 				Link:     support,
@@ -254,6 +422,7 @@ func lockfilesCheck() []*common.DiagnosticCheck {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryLockFile,
+			Code:     common.CodeLockFileOk,
 			Status:   statusOk,
 			Message:  fmt.Sprintf("%d lockfiles all seem to work correctly (for this user).", count),
 			Link:     support,
@@ -270,6 +439,7 @@ func lockpidsCheck() []*common.DiagnosticCheck {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryLockPid,
+			Code:     common.CodeLockPidUnreadable,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("Problem loading lock pids, reason: %v", err),
 			Link:     support,
@@ -285,6 +455,7 @@ func lockpidsCheck() []*common.DiagnosticCheck {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryLockPid,
+			Code:     common.CodeLockPidActive,
 			Status:   level,
 			Message:  entry.Message(),
 			Link:     support,
@@ -294,6 +465,7 @@ func lockpidsCheck() []*common.DiagnosticCheck {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryLockPid,
+			Code:     common.CodeLockPidOk,
 			Status:   statusOk,
 			Message:  "No pending lock files detected.",
 			Link:     support,
@@ -302,6 +474,49 @@ func lockpidsCheck() []*common.DiagnosticCheck {
 	return result
 }
 
+// staleLocksCheck reports lock files under location, which some process
+// has held longer than limit. Lock files itself are always left behind, so
+// only old ones with live holders (see lock pids) are suspicious.
+func staleLocksCheck(location string, limit time.Duration, holders func(string) (pathlib.Lockpids, error)) []*common.DiagnosticCheck {
+	support := settings.Global.DocsLink("troubleshooting")
+	result := []*common.DiagnosticCheck{}
+	lockfiles := pathlib.Glob(location, "*.lck")
+	for _, lockfile := range lockfiles {
+		modified, err := pathlib.Modtime(lockfile)
+		age := time.Since(modified)
+		if err != nil || age < limit {
+			continue
+		}
+		pending, err := holders(lockfile)
+		if err != nil || len(pending) == 0 {
+			continue
+		}
+		pids := make([]string, 0, len(pending))
+		for _, holder := range pending {
+			pids = append(pids, fmt.Sprintf("%d", holder.ProcessID))
+		}
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryLockStale,
+			Code:     common.CodeLockStaleHeld,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Lock file %q is %s old and still held by process(es) %s. If those are stuck or not rcc anymore, stop them; when no rcc is running, lock file can be removed by hand.", lockfile, age.Round(time.Minute), strings.Join(pids, ", ")),
+			Link:     support,
+		})
+	}
+	if len(result) == 0 {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryLockStale,
+			Code:     common.CodeLockStaleOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("None of %d lock files in %q has been held longer than %s.", len(lockfiles), location, limit),
+			Link:     support,
+		})
+	}
+	return result
+}
+
 func anyEnvVarCheck(key string) *common.DiagnosticCheck {
 	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
 	anyVar := os.Getenv(key)
@@ -309,6 +524,7 @@ func anyEnvVarCheck(key string) *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryEnvVarCheck,
+			Code:     common.CodeEnvVarSet,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%s is set to %q. This may cause problems.", key, anyVar),
 			Link:     supportGeneralUrl,
@@ -317,6 +533,7 @@ func anyEnvVarCheck(key string) *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "OS",
 		Category: common.CategoryEnvVarCheck,
+		Code:     common.CodeEnvVarOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("%s is not set, which is good.", key),
 		Link:     supportGeneralUrl,
@@ -330,6 +547,7 @@ func anyPathCheck(key string) *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryPathCheck,
+			Code:     common.CodePathVarSet,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%s is set to %q. This may cause problems.", key, anyPath),
 			Link:     supportGeneralUrl,
@@ -338,12 +556,281 @@ func anyPathCheck(key string) *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "OS",
 		Category: common.CategoryPathCheck,
+		Code:     common.CodePathVarOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("%s is not set, which is good.", key),
 		Link:     supportGeneralUrl,
 	}
 }
 
+func megabytes(value uint64) string {
+	return fmt.Sprintf("%d", value/(1024*1024))
+}
+
+func memorySwapCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	memory, err := hostMemoryStatus()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryMemorySwap,
+			Code:     common.CodeMemoryUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not get memory and swap status, reason: %v", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["memory-total-mb"] = megabytes(memory.Total)
+	details["memory-available-mb"] = megabytes(memory.Available)
+	details[fmt.Sprintf("%s-total-mb", memory.SwapLabel)] = megabytes(memory.SwapTotal)
+	details[fmt.Sprintf("%s-free-mb", memory.SwapLabel)] = megabytes(memory.SwapFree)
+	summary := fmt.Sprintf("memory total %sMB, %s total %sMB, %s free %sMB", megabytes(memory.Total), memory.SwapLabel, megabytes(memory.SwapTotal), memory.SwapLabel, megabytes(memory.SwapFree))
+	if memory.NoSwap && memory.Total < lowMemoryLimit {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryMemorySwap,
+			Code:     common.CodeMemoryLow,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Limited memory and no %s headroom (%s). Large environment builds may get killed by OS.", memory.SwapLabel, summary),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryMemorySwap,
+		Code:     common.CodeMemoryOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Memory status: %s.", summary),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func entropyCheck(details map[string]string, entropy *entropyStatus, err error) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	if entropy == nil && err == nil {
+		return nil
+	}
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryEntropy,
+			Code:     common.CodeEntropyUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not read available entropy, reason: %v", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["entropy-available"] = fmt.Sprintf("%d", entropy.Available)
+	details["kernel-release"] = entropy.Kernel
+	if entropy.modernCrng() {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryEntropy,
+			Code:     common.CodeEntropyModernKernel,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Kernel %s has modern CRNG, so blocking on low entropy is not expected [available entropy %d].", entropy.Kernel, entropy.Available),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if entropy.Available < lowEntropyLimit {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryEntropy,
+			Code:     common.CodeEntropyLow,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Available entropy is only %d (below %d) on kernel %s. TLS handshakes and key generation may stall; consider installing haveged or rng-tools.", entropy.Available, lowEntropyLimit, entropy.Kernel),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryEntropy,
+		Code:     common.CodeEntropyOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Available entropy is %d on kernel %s.", entropy.Available, entropy.Kernel),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func cpuAffinityCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	present, usable, err := hostCpuCounts()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCpuAffinity,
+			Code:     common.CodeCpuAffinityUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not determine usable CPUs, reason: %v", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["cpus-present"] = fmt.Sprintf("%d", present)
+	details["cpus-usable"] = fmt.Sprintf("%d", usable)
+	if usable*2 < present {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCpuAffinity,
+			Code:     common.CodeCpuAffinityRestricted,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("CPU affinity restricts rcc to %d of %d present CPUs. Parallel operations will be slower than expected.", usable, present),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryCpuAffinity,
+		Code:     common.CodeCpuAffinityOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("rcc can use %d of %d present CPUs.", usable, present),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func certificateStoreCheck() *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	_, err := x509.SystemCertPool()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCertificateStore,
+			Code:     common.CodeCertificateStoreMissing,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("System CA certificate store is not available, reason: %v", err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if settings.Global.HasCaBundle() {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryCertificateStore,
+			Code:     common.CodeCertificateStoreBundleOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("System CA certificate store is available, and custom CA bundle %q is used.", common.CaBundleFile()),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryCertificateStore,
+		Code:     common.CodeCertificateStoreOk,
+		Status:   statusOk,
+		Message:  "System CA certificate store is available.",
+		Link:     supportNetworkUrl,
+	}
+}
+
+func readinessCheck(checks []*common.DiagnosticCheck) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	blocking := []string{}
+	seen := make(map[string]bool)
+	for _, check := range checks {
+		label, ok := readinessItems[check.Category]
+		if !ok || seen[label] {
+			continue
+		}
+		if check.Status == statusFail || check.Status == statusFatal {
+			seen[label] = true
+			blocking = append(blocking, label)
+		}
+	}
+	if len(blocking) > 0 {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryReadiness,
+			Code:     common.CodeReadinessBlocked,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("This machine is NOT ready to run rcc. Blocking items: %s.", strings.Join(blocking, ", ")),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryReadiness,
+		Code:     common.CodeReadinessOk,
+		Status:   statusOk,
+		Message:  "This machine is ready to run rcc.",
+		Link:     supportGeneralUrl,
+	}
+}
+
+func homeVariableCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	home := os.Getenv(homeVariable)
+	if len(home) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHomeVariable,
+			Code:     common.CodeHomeVariableUnset,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s is not set. User directories cannot be resolved reliably.", homeVariable),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if !pathlib.IsDir(home) {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHomeVariable,
+			Code:     common.CodeHomeVariableMissing,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s is set to %q, but that directory does not exist.", homeVariable, home),
+			Link:     supportGeneralUrl,
+		}
+	}
+	probe, err := os.CreateTemp(home, ".rcc_home_probe")
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHomeVariable,
+			Code:     common.CodeHomeVariableReadOnly,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s is set to %q, but it is not writable, reason: %v", homeVariable, home, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryHomeVariable,
+		Code:     common.CodeHomeVariableOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("%s is %q, exists and is writable.", homeVariable, home),
+		Link:     supportGeneralUrl,
+	}
+}
+
+// homeConflictCheck compares home variable to alternative one, which some
+// tools prefer (like HOME on Windows), when both are set
+func homeConflictCheck(primary, alternative string) *common.DiagnosticCheck {
+	if len(alternative) == 0 {
+		return nil
+	}
+	home, other := os.Getenv(primary), os.Getenv(alternative)
+	if len(home) == 0 || len(other) == 0 {
+		return nil
+	}
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	if !strings.EqualFold(filepath.Clean(home), filepath.Clean(other)) {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHomeVariable,
+			Code:     common.CodeHomeVariablesDiffer,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s is %q, but %s is %q. Tools disagree where user home is, so configuration and caches may be split or missing.", primary, home, alternative, other),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryHomeVariable,
+		Code:     common.CodeHomeVariablesAgree,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("%s and %s both point to %q.", primary, alternative, home),
+		Link:     supportGeneralUrl,
+	}
+}
+
 func verifySharedDirectory(fullpath string) *common.DiagnosticCheck {
 	shared := pathlib.IsSharedDir(fullpath)
 	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
@@ -351,6 +838,7 @@ func verifySharedDirectory(fullpath string) *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "OS",
 			Category: common.CategoryHolotreeShared,
+			Code:     common.CodeHolotreeNotShared,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%q is not shared. This may cause problems.", fullpath),
 			Link:     supportGeneralUrl,
@@ -359,6 +847,7 @@ func verifySharedDirectory(fullpath string) *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "OS",
 		Category: common.CategoryHolotreeShared,
+		Code:     common.CodeHolotreeSharedOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("%q is shared, which is ok.", fullpath),
 		Link:     supportGeneralUrl,
@@ -379,6 +868,7 @@ func workdirCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "RPA",
 			Category: common.CategoryPathCheck,
+			Code:     common.CodeWorkdirInsideHome,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("Working directory %q is inside ROBOCORP_HOME (%s).", workarea, common.RobocorpHome()),
 			Link:     supportGeneralUrl,
@@ -397,6 +887,7 @@ func robocorpHomeMemberCheck() *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "RPA",
 		Category: common.CategoryRobocorpHomeMembers,
+		Code:     common.CodeRobocorpHomeSharedUsers,
 		Status:   statusWarning,
 		Message:  fmt.Sprintf("More than one user is sharing ROBOCORP_HOME (%s). Those users are: %s.", common.RobocorpHome(), members),
 		Link:     supportGeneralUrl,
@@ -409,6 +900,7 @@ func robocorpHomeCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "RPA",
 			Category: common.CategoryRobocorpHome,
+			Code:     common.CodeRobocorpHomeBadChars,
 			Status:   statusFatal,
 			Message:  fmt.Sprintf("ROBOCORP_HOME (%s) contains characters that makes RPA fail.", common.RobocorpHome()),
 			Link:     supportGeneralUrl,
@@ -421,6 +913,7 @@ func robocorpHomeCheck() *common.DiagnosticCheck {
 			return &common.DiagnosticCheck{
 				Type:     "RPA",
 				Category: common.CategoryRobocorpHome,
+				Code:     common.CodeRobocorpHomeHasUserHome,
 				Status:   statusWarning,
 				Message:  fmt.Sprintf("User home directory %q is inside ROBOCORP_HOME (%s).", userhome, common.RobocorpHome()),
 				Link:     supportGeneralUrl,
@@ -430,40 +923,216 @@ func robocorpHomeCheck() *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "RPA",
 		Category: common.CategoryRobocorpHome,
+		Code:     common.CodeRobocorpHomeOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("ROBOCORP_HOME (%s) is good enough.", common.RobocorpHome()),
 		Link:     supportGeneralUrl,
 	}
 }
 
-func dnsLookupCheck(site string) *common.DiagnosticCheck {
-	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
-	found, err := net.LookupHost(site)
+func invalidUtf8Names(directory string, depth int, collector []string) []string {
+	entries, err := os.ReadDir(directory)
 	if err != nil {
-		return &common.DiagnosticCheck{
-			Type:     "network",
-			Category: common.CategoryNetworkDNS,
-			Status:   statusFail,
-			Message:  fmt.Sprintf("DNS lookup %q failed: %v", site, err),
-			Link:     supportNetworkUrl,
-		}
+		return collector
 	}
-	return &common.DiagnosticCheck{
-		Type:     "network",
-		Category: common.CategoryNetworkDNS,
-		Status:   statusOk,
-		Message:  fmt.Sprintf("%s found [DNS query]: %v", site, found),
-		Link:     supportNetworkUrl,
+	for _, entry := range entries {
+		fullpath := filepath.Join(directory, entry.Name())
+		if !utf8.ValidString(entry.Name()) {
+			collector = append(collector, fullpath)
+			continue
+		}
+		if depth > 1 && entry.IsDir() {
+			collector = invalidUtf8Names(fullpath, depth-1, collector)
+		}
 	}
+	return collector
 }
 
-func condaHeadCheck() *common.DiagnosticCheck {
-	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+func robocorpHomeNamesCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	invalid := invalidUtf8Names(common.RobocorpHome(), 2, []string{})
+	if len(invalid) > 0 {
+		names := make([]string, 0, len(invalid))
+		for _, name := range invalid {
+			names = append(names, fmt.Sprintf("%q", name))
+		}
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryRobocorpHomeNames,
+			Code:     common.CodeRobocorpHomeBadNames,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("ROBOCORP_HOME (%s) has %d entries with invalid UTF-8 names: %s", common.RobocorpHome(), len(invalid), strings.Join(names, ", ")),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryRobocorpHomeNames,
+		Code:     common.CodeRobocorpHomeNamesOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("ROBOCORP_HOME (%s) entry names are valid UTF-8.", common.RobocorpHome()),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func timedOut(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout())
+}
+
+func dnsLookupCheck(site, server string, timeout time.Duration) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resolver, via := dnsResolver(server)
+	network := settings.Global.IpNetwork()
+	if network != "ip" {
+		via = fmt.Sprintf("%s, %s only", via, settings.Global.IpFamily())
+	}
+	common.Debug("DNS lookup of %q started [timeout %s, %s].", site, timeout, via)
+	started := time.Now()
+	found, err := lookupAddresses(ctx, resolver, network, site)
+	elapsed := time.Since(started)
+	common.Debug("DNS lookup of %q finished in %s with %d addresses [error: %v].", site, elapsed.Round(time.Millisecond), len(found), err)
+	if err != nil && timedOut(err) {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkDNS,
+			Code:       common.CodeDnsLookupTimeout,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("DNS lookup %q timed out after %s [%s].", site, timeout, via),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed.Milliseconds(),
+			Command:    nslookupCommand(site, server),
+		}
+	}
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkDNS,
+			Code:       common.CodeDnsLookupFailed,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("DNS lookup %q failed [%s]: %v", site, via, err),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed.Milliseconds(),
+			Command:    nslookupCommand(site, server),
+		}
+	}
+	if elapsed > slowLatencyLimit {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkDNS,
+			Code:       common.CodeDnsLookupSlow,
+			Status:     statusWarning,
+			Message:    fmt.Sprintf("%s found [DNS query via %s], but lookup was slow (%dms): %v", site, via, elapsed.Milliseconds(), found),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed.Milliseconds(),
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:       "network",
+		Category:   common.CategoryNetworkDNS,
+		Code:       common.CodeDnsLookupOk,
+		Status:     statusOk,
+		Message:    fmt.Sprintf("%s found [DNS query via %s]: %v", site, via, found),
+		Link:       supportNetworkUrl,
+		DurationMs: elapsed.Milliseconds(),
+	}
+}
+
+func nslookupCommand(site, server string) string {
+	if len(server) > 0 {
+		return fmt.Sprintf("nslookup %s %s", site, server)
+	}
+	return fmt.Sprintf("nslookup %s", site)
+}
+
+func lookupAddresses(ctx context.Context, resolver *net.Resolver, network, site string) ([]string, error) {
+	found, err := resolver.LookupIP(ctx, network, site)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(found))
+	for _, address := range found {
+		addresses = append(addresses, address.String())
+	}
+	return addresses, nil
+}
+
+func lookupFamily(site, network string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addresses, err := lookupAddresses(ctx, net.DefaultResolver, network, site)
+	var dnsError *net.DNSError
+	if err != nil && errors.As(err, &dnsError) && dnsError.IsNotFound {
+		return noRecords, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(addresses, ", "), nil
+}
+
+func dnsFamilyCheck(site string, timeout time.Duration) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	ipv4, err4 := lookupFamily(site, "ip4", timeout)
+	ipv6, err6 := lookupFamily(site, "ip6", timeout)
+	switch {
+	case err4 == nil && err6 == nil && ipv4 == noRecords && ipv6 == noRecords:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSFamily,
+			Code:     common.CodeDnsFamilyNoAddresses,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Neither IPv4 nor IPv6 lookup of %q found any addresses.", site),
+			Link:     supportNetworkUrl,
+		}
+	case err4 != nil && err6 != nil:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSFamily,
+			Code:     common.CodeDnsFamilyFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Neither IPv4 nor IPv6 lookup of %q worked: %v; %v", site, err4, err6),
+			Link:     supportNetworkUrl,
+		}
+	case err4 != nil:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSFamily,
+			Code:     common.CodeDnsFamilyNoIPv4,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("IPv6 lookup of %q resolves [%s], but IPv4 lookup failed: %v", site, ipv6, err4),
+			Link:     supportNetworkUrl,
+		}
+	case err6 != nil:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSFamily,
+			Code:     common.CodeDnsFamilyNoIPv6,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("IPv4 lookup of %q resolves [%s], but IPv6 lookup failed: %v", site, ipv4, err6),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkDNSFamily,
+		Code:     common.CodeDnsFamilyOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("%s address families [DNS query]: IPv4 [%s], IPv6 [%s]", site, ipv4, ipv6),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func condaHeadCheck() *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
 	client, err := cloud.NewClient(settings.Global.CondaLink(""))
 	if err != nil {
 		return &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkLink,
+			Code:     common.CodeNetworkClientFailed,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%v: %v", settings.Global.CondaLink(""), err),
 			Link:     supportNetworkUrl,
@@ -475,6 +1144,7 @@ func condaHeadCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkHEAD,
+			Code:     common.CodeHeadFailed,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("Conda canary download failed: %d %v", response.Status, response.Err),
 			Link:     supportNetworkUrl,
@@ -483,6 +1153,7 @@ func condaHeadCheck() *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "network",
 		Category: common.CategoryNetworkHEAD,
+		Code:     common.CodeHeadOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("Conda canary download successful [HEAD request]: %s", settings.Global.CondaLink(condaCanaryUrl)),
 		Link:     supportNetworkUrl,
@@ -496,6 +1167,7 @@ func pypiHeadCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkLink,
+			Code:     common.CodeNetworkClientFailed,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%v: %v", settings.Global.PypiLink(""), err),
 			Link:     supportNetworkUrl,
@@ -507,6 +1179,7 @@ func pypiHeadCheck() *common.DiagnosticCheck {
 		return &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkHEAD,
+			Code:     common.CodeHeadFailed,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("PyPI canary download failed: %d %v", response.Status, response.Err),
 			Link:     supportNetworkUrl,
@@ -515,40 +1188,464 @@ func pypiHeadCheck() *common.DiagnosticCheck {
 	return &common.DiagnosticCheck{
 		Type:     "network",
 		Category: common.CategoryNetworkHEAD,
+		Code:     common.CodeHeadOk,
 		Status:   statusOk,
 		Message:  fmt.Sprintf("PyPI canary download successful [HEAD request]: %s", settings.Global.PypiLink(pypiCanaryUrl)),
 		Link:     supportNetworkUrl,
 	}
 }
 
-func canaryDownloadCheck() *common.DiagnosticCheck {
+func micromambaVersionCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	binary := conda.BinMicromamba()
+	expected, expectedText := conda.AsVersion(blobs.MicromambaVersion())
+	details["micromamba.expected"] = expectedText
+	if !pathlib.IsFile(binary) {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaVersion,
+			Code:     common.CodeMicromambaNotInstalled,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Micromamba v%s is not yet installed at %q, it will be installed when needed.", expectedText, binary),
+			Link:     supportGeneralUrl,
+		}
+	}
+	versionText, err := conda.ProbeMicromambaVersion()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaVersion,
+			Code:     common.CodeMicromambaUnrunnable,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Micromamba at %q could not be run to get its version, reason: %v. Remove it and let rcc reinstall it.", binary, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	actual, actualText := conda.AsVersion(versionText)
+	if actual != expected {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaVersion,
+			Code:     common.CodeMicromambaVersionMismatch,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Micromamba at %q reports version %q, but rcc expects version %q. Binary may be stale or replaced; remove it and let rcc reinstall it.", binary, actualText, expectedText),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryMicromambaVersion,
+		Code:     common.CodeMicromambaVersionOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Micromamba at %q reports expected version %q.", binary, actualText),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func virtualPackagesCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	if !pathlib.IsFile(conda.BinMicromamba()) {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryVirtualPackages,
+			Code:     common.CodeVirtualPackagesSkipped,
+			Status:   statusOk,
+			Message:  "Micromamba is not yet installed, so conda virtual packages were not checked.",
+			Link:     supportGeneralUrl,
+		}
+	}
+	packages, err := conda.MicromambaVirtualPackages()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryVirtualPackages,
+			Code:     common.CodeVirtualPackagesUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not detect conda virtual packages, reason: %v", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["conda-virtual-packages"] = strings.Join(packages, ", ")
+	for _, entry := range packages {
+		parts := strings.SplitN(entry, "=", 3)
+		minimum, ok := virtualPackageMinimums[parts[0]]
+		if !ok || len(parts) < 2 {
+			continue
+		}
+		version, _ := conda.AsVersion(parts[1])
+		required, _ := conda.AsVersion(minimum)
+		if version < required {
+			return &common.DiagnosticCheck{
+				Type:     "RPA",
+				Category: common.CategoryVirtualPackages,
+				Code:     common.CodeVirtualPackageTooOld,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Conda virtual package %s=%s is below commonly required %s. Many packages will have no installable candidates.", parts[0], parts[1], minimum),
+				Link:     supportGeneralUrl,
+			}
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryVirtualPackages,
+		Code:     common.CodeVirtualPackagesOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Conda virtual packages are: %s", strings.Join(packages, ", ")),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func telemetryCheck() *common.DiagnosticCheck {
 	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
-	client, err := cloud.NewClient(settings.Global.DownloadsLink(""))
+	endpoint := settings.Global.TelemetryURL()
+	if !xviper.CanTrack() || len(endpoint) == 0 {
+		return nil
+	}
+	client, err := cloud.NewClient(endpoint)
 	if err != nil {
 		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTelemetry,
+			Code:     common.CodeTelemetryUnusable,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Telemetry endpoint %q is not usable: %v", endpoint, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	response := client.WithTimeout(5 * time.Second).Head(client.NewRequest("/"))
+	if response.Err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTelemetry,
+			Code:     common.CodeTelemetryBlocked,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Telemetry is enabled, but endpoint %q is blocked: %v. On restricted networks, consider 'rcc configure identity --do-not-track'.", endpoint, response.Err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTelemetry,
+		Code:     common.CodeTelemetryOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Telemetry is enabled, and endpoint %q is reachable [HEAD status %d].", endpoint, response.Status),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func httpProtocolCheck(label string, response *cloud.Response) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	if response.Proto == "HTTP/1.0" {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProtocol,
+			Code:     common.CodeProtocolLegacy,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s response used legacy %s protocol. Maybe there is outdated proxy slowing down downloads.", label, response.Proto),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if response.Header != nil && strings.EqualFold(response.Header.Get("Connection"), "close") {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProtocol,
+			Code:     common.CodeProtocolConnectionClose,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%s response used %s protocol with forced connection close. Maybe there is proxy preventing keep-alive.", label, response.Proto),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkProtocol,
+		Code:     common.CodeProtocolKeepAlive,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("%s response used %s protocol with keep-alive.", label, response.Proto),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func clockSkewCheck(response *cloud.Response) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	if response.Header == nil {
+		return nil
+	}
+	remote, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+	skew := time.Since(remote).Round(time.Second)
+	offset := skew
+	if offset < 0 {
+		offset = -offset
+	}
+	status := statusOk
+	switch {
+	case offset > clockSkewFail:
+		status = statusFail
+	case offset > clockSkewWarning:
+		status = statusWarning
+	}
+	if status != statusOk {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryClockSkew,
+			Code:     common.CodeClockSkewed,
+			Status:   status,
+			Message:  fmt.Sprintf("Local clock differs %s from canary server Date header. TLS verification and cloud authentication may fail. Fix your system time.", skew),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryClockSkew,
+		Code:     common.CodeClockSkewOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Local clock differs %s from canary server Date header.", skew),
+		Link:     supportGeneralUrl,
+	}
+}
+
+// timeZoneCheck reports configured zone and UTC offset, and warns when zone
+// database is missing, since then named zones (and TZ) silently become UTC
+func timeZoneCheck(details map[string]string, now time.Time, load func(string) (*time.Location, error)) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	name, _ := now.Zone()
+	offset := now.Format("-07:00")
+	details["time-zone"] = fmt.Sprintf("%s (%s)", now.Location(), name)
+	details["time-zone-utc-offset"] = offset
+	zone := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if len(zone) > 0 && !filepath.IsAbs(zone) {
+		_, err := load(zone)
+		if err != nil {
+			return &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryTimeZone,
+				Code:     common.CodeTimeZoneInvalid,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Time zone TZ=%q cannot be loaded, so UTC is used instead, reason: %v", zone, err),
+				Link:     supportGeneralUrl,
+			}
+		}
+	}
+	if zoneDatabaseNeeded {
+		_, err := load(tzdataProbeZone)
+		if err != nil {
+			return &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryTimeZone,
+				Code:     common.CodeTimeZoneNoDatabase,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Time zone database cannot be loaded (tzdata missing?), so named time zones fall back to UTC, reason: %v", err),
+				Link:     supportGeneralUrl,
+			}
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryTimeZone,
+		Code:     common.CodeTimeZoneOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Time zone is %s (%s) with UTC offset %s.", now.Location(), name, offset),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func attemptsMade(attempts int) string {
+	if attempts == 1 {
+		return "1 attempt"
+	}
+	return fmt.Sprintf("%d attempts", attempts)
+}
+
+// looksLikeCaptivePortal tells if unexpected canary content is HTML page,
+// which is what network login portals serve instead of real content
+func looksLikeCaptivePortal(response *cloud.Response, expected string) bool {
+	if string(response.Body) == expected {
+		return false
+	}
+	if response.Header != nil && strings.Contains(strings.ToLower(response.Header.Get("Content-Type")), "text/html") {
+		return true
+	}
+	body := strings.ToLower(strings.TrimSpace(string(response.Body)))
+	return strings.HasPrefix(body, "<!doctype html") || strings.HasPrefix(body, "<html") || strings.Contains(body, "<html")
+}
+
+// canaryWrapper tells what extra content (like BOM or newlines) surrounds
+// expected canary content in body; ok is false on genuine mismatch
+func canaryWrapper(body []byte, expected string) (wrapper string, ok bool) {
+	text := string(body)
+	core := strings.TrimSpace(expected)
+	trimmed := strings.TrimSpace(strings.TrimPrefix(text, utf8Bom))
+	if len(core) == 0 || trimmed != core {
+		return "", false
+	}
+	at := strings.Index(text, core)
+	parts := []string{}
+	if strings.HasPrefix(text, utf8Bom) {
+		parts = append(parts, "UTF-8 BOM")
+	}
+	if leading := strings.TrimPrefix(text[:at], utf8Bom); len(leading) > 0 {
+		parts = append(parts, fmt.Sprintf("leading %q", leading))
+	}
+	if trailing := text[at+len(core):]; len(trailing) > 0 {
+		parts = append(parts, fmt.Sprintf("trailing %q", trailing))
+	}
+	return strings.Join(parts, ", "), true
+}
+
+func canaryDump(body []byte) string {
+	if len(body) > 64 {
+		body = body[:64]
+	}
+	return hex.Dump(body)
+}
+
+// splitLink splits full URL into endpoint (for client) and resource part
+func splitLink(link string) (endpoint, resource string) {
+	parsed, err := url.Parse(link)
+	if err != nil || len(parsed.Host) == 0 {
+		return link, ""
+	}
+	return fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host), parsed.RequestURI()
+}
+
+func canaryDownloadCheck(timeout time.Duration) []*common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.CanaryHelpLink()
+	link := settings.Global.CanaryURL()
+	expected := settings.Global.CanaryContent()
+	endpoint, resource := splitLink(link)
+	client, err := cloud.NewClient(endpoint)
+	if err != nil {
+		return []*common.DiagnosticCheck{&common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkLink,
+			Code:     common.CodeNetworkClientFailed,
 			Status:   statusFail,
-			Message:  fmt.Sprintf("%v: %v", settings.Global.DownloadsLink(""), err),
+			Message:  fmt.Sprintf("%v: %v", link, err),
 			Link:     supportNetworkUrl,
+		}}
+	}
+	request := client.NewRequest(resource)
+	common.Debug("Canary download of %q started [timeout %s, %d attempts].", link, timeout, canaryAttempts)
+	started := time.Now()
+	response := client.WithTimeout(timeout).GetWithRetry(request, canaryAttempts)
+	elapsed := time.Since(started).Milliseconds()
+	common.Debug("Canary download finished in %dms with status %d after %s [error: %v].", elapsed, response.Status, attemptsMade(response.Attempts), response.Err)
+	if response.Err == nil && string(response.Body) != expected {
+		common.Debug("Canary body differs from expected, first bytes are:\n%s", canaryDump(response.Body))
+	}
+	wrapper, wrapped := canaryWrapper(response.Body, expected)
+	result := make([]*common.DiagnosticCheck, 0, 3)
+	if response.Err != nil {
+		layer := probeLayers(link, settings.Global.ConfiguredHttpTransport(), timeout)
+		failure := fmt.Sprintf("%v", response.Err)
+		if timedOut(response.Err) {
+			failure = fmt.Sprintf("timed out after %s", timeout)
+		}
+		result = append(result, &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   layer.category,
+			Code:       layer.code,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("Canary download from %s failed at %s (%s): %s", link, layer.String(), attemptsMade(response.Attempts), failure),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed,
+		})
+	} else if response.Status == 200 && string(response.Body) != expected && wrapped {
+		result = append(result, &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCanary,
+			Code:       common.CodeCanaryWrapped,
+			Status:     statusWarning,
+			Message:    fmt.Sprintf("Canary download from %s matched expected content only after trimming extra content [%s]. Maybe proxy or filter rewrites responses.", link, wrapper),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed,
+		})
+	} else if response.Status == 200 && looksLikeCaptivePortal(response, expected) {
+		result = append(result, &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCaptivePortal,
+			Code:       common.CodeCaptivePortal,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("Captive portal detected: canary download %s returned HTML login page instead of expected content. Please open a browser and authenticate to the network (hotel, airport, guest WiFi), then retry.", link),
+			Link:       settings.Global.DocsLink("troubleshooting/firewall-and-proxies#captive-portals"),
+			DurationMs: elapsed,
+		})
+	} else if response.Status != 200 || string(response.Body) != expected {
+		result = append(result, &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCanaryHTTP,
+			Code:       common.CodeCanaryHttpStatus,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("Canary download from %s failed at HTTP level (%s): status %d, body %q", link, attemptsMade(response.Attempts), response.Status, response.Body),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed,
+		})
+	} else {
+		result = append(result, &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCanary,
+			Code:       common.CodeCanaryOk,
+			Status:     statusOk,
+			Message:    fmt.Sprintf("Canary download successful [GET request]: %s", link),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed,
+		})
+	}
+	if response.Err == nil {
+		result = append(result, httpProtocolCheck("Canary download", response))
+		if skew := clockSkewCheck(response); skew != nil {
+			result = append(result, skew)
 		}
 	}
-	request := client.NewRequest(canaryUrl)
+	return result
+}
+
+func rangeRequestCheck() *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	link := settings.Global.CanaryURL()
+	endpoint, resource := splitLink(link)
+	client, err := cloud.NewClient(endpoint)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkRange,
+			Code:     common.CodeRangeUnusable,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Range request check could not use %q, reason: %v", link, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	request := client.NewRequest(resource)
+	request.Headers["Range"] = "bytes=0-9"
 	response := client.Get(request)
-	if response.Status != 200 || string(response.Body) != "Used to testing connections" {
+	if response.Err != nil {
 		return &common.DiagnosticCheck{
 			Type:     "network",
-			Category: common.CategoryNetworkCanary,
-			Status:   statusFail,
-			Message:  fmt.Sprintf("Canary download failed: %d: %v %s", response.Status, response.Err, response.Body),
+			Category: common.CategoryNetworkRange,
+			Code:     common.CodeRangeFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Range request to %s failed: %v", link, response.Err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if response.Status != 206 || len(response.Body) != 10 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkRange,
+			Code:     common.CodeRangeIgnored,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Range requests are not honored by %s [status %d, %d bytes]. Interrupted downloads will restart from scratch; check proxy configuration.", link, response.Status, len(response.Body)),
 			Link:     supportNetworkUrl,
 		}
 	}
 	return &common.DiagnosticCheck{
 		Type:     "network",
-		Category: common.CategoryNetworkCanary,
+		Category: common.CategoryNetworkRange,
+		Code:     common.CodeRangeOk,
 		Status:   statusOk,
-		Message:  fmt.Sprintf("Canary download successful [GET request]: %s", settings.Global.DownloadsLink(canaryUrl)),
+		Message:  fmt.Sprintf("Range requests work with %s [206 Partial Content].", link),
 		Link:     supportNetworkUrl,
 	}
 }
@@ -561,7 +1658,129 @@ func jsonDiagnostics(sink io.Writer, details *common.DiagnosticStatus) {
 	fmt.Fprintln(sink, form)
 }
 
+func jsonLineDiagnostics(sink io.Writer, details *common.DiagnosticStatus) {
+	form, err := details.AsJsonLine()
+	if err != nil {
+		pretty.Exit(1, "Error: %s", err)
+	}
+	fmt.Fprintln(sink, form)
+}
+
+type diagnosticsTrailer struct {
+	Schema    int                       `json:"schema"`
+	Readiness *common.DiagnosticCheck   `json:"readiness,omitempty"`
+	Details   map[string]string         `json:"details"`
+	Summary   *common.DiagnosticSummary `json:"summary"`
+	NextSteps []string                  `json:"next-steps,omitempty"`
+}
+
+func streamLine(sink io.Writer, content interface{}) {
+	body, err := json.Marshal(content)
+	if err != nil {
+		pretty.Exit(1, "Error: %s", err)
+	}
+	fmt.Fprintln(sink, string(body))
+}
+
+// streamChecks makes options write each check as JSON line as soon as it
+// finishes, and returns function which writes rest of checks and details
+// (as last line) once whole run is done
+func streamChecks(sink io.Writer, options *DiagnosticsOptions) func(*common.DiagnosticStatus) {
+	streamed := make(map[*common.DiagnosticCheck]bool)
+	previous := options.OnCheck
+	options.OnCheck = func(check *common.DiagnosticCheck) {
+		if previous != nil {
+			previous(check)
+		}
+		streamed[check] = true
+		if len(options.Categories) > 0 && !check.InCategory(options.Categories...) {
+			return
+		}
+		if len(options.FailuresAt) > 0 && common.StatusSeverity(check.Status) < common.StatusSeverity(options.FailuresAt) {
+			return
+		}
+		streamLine(sink, check)
+	}
+	return func(result *common.DiagnosticStatus) {
+		for _, check := range result.Checks {
+			if !streamed[check] {
+				streamLine(sink, check)
+			}
+		}
+		streamLine(sink, &diagnosticsTrailer{
+			Schema:    common.DiagnosticsSchema,
+			Readiness: result.Readiness,
+			Details:   result.Details,
+			Summary:   result.Summarize(),
+			NextSteps: result.NextSteps,
+		})
+	}
+}
+
+// checkSink receives finished checks one by one, for outputs which are not
+// plain files, like syslog
+type checkSink interface {
+	Emit(check *common.DiagnosticCheck) error
+	Close() error
+}
+
+// syslogSeverity maps check status into syslog severity name
+func syslogSeverity(status string) string {
+	switch status {
+	case statusFatal:
+		return "crit"
+	case statusFail:
+		return "err"
+	case statusWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func syslogLine(check *common.DiagnosticCheck) string {
+	return fmt.Sprintf("category=%d code=%d type=%s status=%s message=%q url=%s", check.Category, check.Code, check.Type, check.Status, check.Message, check.Link)
+}
+
+// lineSink is fallback sink, which writes syslog like lines into writer
+type lineSink struct {
+	io.Writer
+}
+
+func (it *lineSink) Emit(check *common.DiagnosticCheck) error {
+	_, err := fmt.Fprintf(it, "<%s> %s\n", syslogSeverity(check.Status), syslogLine(check))
+	return err
+}
+
+func (it *lineSink) Close() error {
+	return nil
+}
+
+func sinkDiagnostics(sink checkSink, result *common.DiagnosticStatus) error {
+	for _, check := range result.Checks {
+		err := sink.Emit(check)
+		if err != nil {
+			return err
+		}
+	}
+	if result.Readiness != nil {
+		return sink.Emit(result.Readiness)
+	}
+	return nil
+}
+
+func yamlDiagnostics(sink io.Writer, details *common.DiagnosticStatus) {
+	form, err := details.AsYaml()
+	if err != nil {
+		pretty.Exit(1, "Error: %s", err)
+	}
+	fmt.Fprint(sink, form)
+}
+
 func humaneDiagnostics(sink io.Writer, details *common.DiagnosticStatus, showStatistics bool) {
+	if details.Readiness != nil {
+		fmt.Fprintf(sink, "Readiness: %s %s\n\n", details.Readiness.Status, details.Readiness.Message)
+	}
 	fmt.Fprintln(sink, "Diagnostics:")
 	keys := make([]string, 0, len(details.Details))
 	for key, _ := range details.Details {
@@ -575,17 +1794,77 @@ func humaneDiagnostics(sink io.Writer, details *common.DiagnosticStatus, showSta
 	fmt.Fprintln(sink, "")
 	fmt.Fprintln(sink, "Checks:")
 	for _, check := range details.Checks {
-		fmt.Fprintf(sink, " - %-8s %-8s %s\n", check.Type, check.Status, check.Message)
+		if check.DurationMs > 0 {
+			fmt.Fprintf(sink, " - %-8s %-8s %s [%dms]\n", check.Type, check.Status, check.Message, check.DurationMs)
+		} else {
+			fmt.Fprintf(sink, " - %-8s %-8s %s\n", check.Type, check.Status, check.Message)
+		}
+		if len(check.Command) > 0 && check.Status != statusOk {
+			fmt.Fprintf(sink, "   %-8s %-8s %s\n", "", "run:", check.Command)
+		}
 	}
-	if !showStatistics {
-		return
+	humaneSummary(sink, details.Summarize(), sink == os.Stdout || sink == os.Stderr)
+	if details.DurationMs > 0 {
+		fmt.Fprintf(sink, "Duration: %dms\n", details.DurationMs)
 	}
-	count, body := journal.MakeStatistics(12, false, false, false, false)
-	if count > 4 {
-		fmt.Fprintln(sink, "")
-		fmt.Fprintln(sink, "Statistics:")
+	if showStatistics {
+		count, body := journal.MakeStatistics(12, false, false, false, false)
+		if count > 4 {
+			fmt.Fprintln(sink, "")
+			fmt.Fprintln(sink, "Statistics:")
+			fmt.Fprintln(sink, "")
+			fmt.Fprintln(sink, string(body))
+		}
+	}
+	if len(details.NextSteps) > 0 {
 		fmt.Fprintln(sink, "")
-		fmt.Fprintln(sink, string(body))
+		fmt.Fprintln(sink, "Next steps:")
+		for at, step := range details.NextSteps {
+			fmt.Fprintf(sink, " %2d. %s\n", at+1, step)
+		}
+	}
+}
+
+func statusColor(status string) string {
+	switch status {
+	case statusOk:
+		return pretty.Green
+	case statusWarning:
+		return pretty.Yellow
+	case statusFail, statusFatal:
+		return pretty.Red
+	}
+	return ""
+}
+
+func humaneSummary(sink io.Writer, summary *common.DiagnosticSummary, colored bool) {
+	statuses := []string{}
+	for _, status := range []string{statusOk, statusWarning, statusFail, statusFatal} {
+		count := summary.Statuses[status]
+		if count == 0 && status == statusFatal {
+			continue
+		}
+		if colored && count > 0 {
+			statuses = append(statuses, fmt.Sprintf("%s%d %s%s", statusColor(status), count, status, pretty.Reset))
+		} else {
+			statuses = append(statuses, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	types := []string{}
+	for _, kind := range set.Keys(summary.Types) {
+		types = append(types, fmt.Sprintf("%d %s", summary.Types[kind], kind))
+	}
+	categories := []string{}
+	for _, category := range set.Keys(summary.Categories) {
+		categories = append(categories, fmt.Sprintf("%s (%d)", category, summary.Categories[category]))
+	}
+	fmt.Fprintln(sink, "")
+	fmt.Fprintf(sink, "Summary: %s\n", strings.Join(statuses, ", "))
+	if len(types) > 0 {
+		fmt.Fprintf(sink, "By type: %s\n", strings.Join(types, ", "))
+	}
+	if len(categories) > 0 {
+		fmt.Fprintf(sink, "By category: %s\n", strings.Join(categories, ", "))
 	}
 }
 
@@ -600,6 +1879,29 @@ func fileIt(filename string) (io.WriteCloser, error) {
 	return file, nil
 }
 
+func appendIt(filename string) (io.WriteCloser, error) {
+	if len(filename) == 0 {
+		return os.Stdout, nil
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+type discarder struct {
+	io.Writer
+}
+
+func (it discarder) Close() error {
+	return nil
+}
+
+func discardIt(string) (io.WriteCloser, error) {
+	return discarder{io.Discard}, nil
+}
+
 func ProduceNetDiagnostics(body []byte, json bool) (*common.DiagnosticStatus, error) {
 	config, err := parseNetworkDiagnosticConfig(body)
 	if err != nil {
@@ -610,6 +1912,7 @@ func ProduceNetDiagnostics(body []byte, json bool) (*common.DiagnosticStatus, er
 		Checks:  []*common.DiagnosticCheck{},
 	}
 	networkDiagnostics(config, result)
+	result.NextSteps = result.Remediations()
 	if json {
 		jsonDiagnostics(os.Stdout, result)
 	} else {
@@ -618,25 +1921,158 @@ func ProduceNetDiagnostics(body []byte, json bool) (*common.DiagnosticStatus, er
 	return nil, nil
 }
 
-func ProduceDiagnostics(filename, robotfile string, json, production, quick bool) (*common.DiagnosticStatus, error) {
-	file, err := fileIt(filename)
+func diagnosticsTempFile(format string) (string, error) {
+	file, err := os.CreateTemp("", fmt.Sprintf("rcc_diagnostics_*.%s", format))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer file.Close()
-	result := runDiagnostics(quick)
-	if len(robotfile) > 0 {
-		addRobotDiagnostics(robotfile, result, production)
+	err = file.Chmod(0o600)
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
 	}
-	settings.Global.Diagnostics(result)
+	return file.Name(), nil
+}
+
+func ProduceDiagnostics(filename, robotfile string, json, production bool, options *DiagnosticsOptions) (*common.DiagnosticStatus, error) {
+	format := formatText
 	if json {
-		jsonDiagnostics(file, result)
+		format = formatJson
+	}
+	return ProduceDiagnosticsFormat(filename, robotfile, format, production, options)
+}
+
+func ProduceDiagnosticsFormat(filename, robotfile, format string, production bool, options *DiagnosticsOptions) (result *common.DiagnosticStatus, err error) {
+	if format != formatText && format != formatJson && format != formatYaml && format != formatHtml {
+		return nil, fmt.Errorf("Unknown diagnostics output format %q, use one of: %s, %s, %s, %s", format, formatText, formatJson, formatYaml, formatHtml)
+	}
+	if options.Stream && format != formatText && format != formatJson {
+		return nil, fmt.Errorf("Option --stream always produces JSON lines and cannot be combined with --format %s", format)
+	}
+	err = validateSelectors(append(options.Only, options.Skip...))
+	if err != nil {
+		return nil, err
+	}
+	if unknown := validateSelectors(settings.Global.SuppressedChecks()); unknown != nil {
+		pretty.Warning("settings.yaml diagnostics/suppress-checks: %v", unknown)
+	}
+	if len(options.FailuresAt) > 0 && common.StatusSeverity(options.FailuresAt) < 1 {
+		return nil, fmt.Errorf("Unknown failure threshold %q, use one of: %s, %s, %s", options.FailuresAt, statusWarning, statusFail, statusFatal)
+	}
+	if len(options.ConnectTo) > 0 && len(options.tlsHosts()) == 0 {
+		return nil, fmt.Errorf("Option --connect-to needs also --tls-host or --sni, to know which host to check")
+	}
+	if options.DryRun {
+		file, err := fileIt(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return &common.DiagnosticStatus{}, printPlan(file, plannedChecks(options), settings.Global.SuppressedChecks(), format == formatJson)
+	}
+	saved := len(filename) == 0 && options.Save
+	if saved {
+		if format == formatText || options.Stream {
+			format = formatJson
+		}
+		filename, err = diagnosticsTempFile(format)
+		if err != nil {
+			return nil, err
+		}
+		tempfile := filename
+		defer func() {
+			if err != nil {
+				os.Remove(tempfile)
+			}
+		}()
+	}
+	var sink checkSink
+	if options.Syslog {
+		sink, err = syslogIt(syslogTag)
+		if err != nil {
+			return nil, err
+		}
+		defer sink.Close()
+	}
+	opener := fileIt
+	if options.Append {
+		opener = appendIt
+	}
+	if sink != nil {
+		opener = discardIt
+	}
+	file, err := opener(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var finish func(*common.DiagnosticStatus)
+	if options.Stream && sink == nil {
+		format = formatJson
+		finish = streamChecks(file, options)
+	}
+	if len(options.tlsHosts()) > 0 {
+		result = tlsHostDiagnostics(options.tlsHosts(), options.tlsRoute())
 	} else {
+		result = RunDiagnostics(options)
+		if len(robotfile) > 0 {
+			addRobotDiagnostics(robotfile, result, production)
+		}
+		settings.Global.Diagnostics(result)
+		result.Readiness = readinessCheck(result.Checks)
+	}
+	if len(options.Categories) > 0 {
+		result = result.FilterByCategory(options.Categories...)
+	}
+	if len(options.FailuresAt) > 0 {
+		result = result.FilterByStatus(options.FailuresAt)
+	}
+	result.OmitDetails(options.OmitDetails)
+	result.NextSteps = result.Remediations()
+	switch {
+	case sink != nil:
+		err = sinkDiagnostics(sink, result)
+		if err != nil {
+			return result, err
+		}
+	case finish != nil:
+		finish(result)
+	case format == formatJson && options.Append:
+		jsonLineDiagnostics(file, result)
+	case format == formatJson:
+		jsonDiagnostics(file, result)
+	case format == formatYaml:
+		yamlDiagnostics(file, result)
+	case format == formatHtml:
+		htmlDiagnostics(file, result)
+	default:
 		humaneDiagnostics(file, result, true)
 	}
+	if len(options.JsonFile) > 0 {
+		err = jsonDiagnosticsFile(options.JsonFile, result)
+		if err != nil {
+			return result, err
+		}
+	}
+	if saved {
+		common.Stdout("%s\n", filename)
+	}
 	return result, nil
 }
 
+// jsonDiagnosticsFile writes additional JSON copy of already computed
+// results, so that text and JSON can be had from one run
+func jsonDiagnosticsFile(filename string, result *common.DiagnosticStatus) error {
+	file, err := fileIt(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	jsonDiagnostics(file, result)
+	return nil
+}
+
 type Unmarshaler func([]byte, interface{}) error
 
 func diagnoseFilesUnmarshal(tool Unmarshaler, label, rootdir string, paths []string, target *common.DiagnosticStatus) {
@@ -700,6 +2136,7 @@ func RunRobotDiagnostics(robotfile string, production bool) *common.DiagnosticSt
 
 func PrintRobotDiagnostics(robotfile string, json, production bool) error {
 	result := RunRobotDiagnostics(robotfile, production)
+	result.NextSteps = result.Remediations()
 	if json {
 		jsonDiagnostics(os.Stdout, result)
 	} else {
@@ -0,0 +1,122 @@
+package operations
+
+import (
+	"encoding/binary"
+	"runtime"
+	"strings"
+
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/shell"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	restrictiveMounts = []struct {
+		mask uint32
+		name string
+	}{
+		{unix.MNT_RDONLY, "ro"},
+		{unix.MNT_NOEXEC, "noexec"},
+		{unix.MNT_NOSUID, "nosuid"},
+		{unix.MNT_NODEV, "nodev"},
+	}
+	buildTools         = []string{"clang", "clang++", "make"}
+	networkFilesystems = map[string]bool{
+		"nfs":    true,
+		"smbfs":  true,
+		"afpfs":  true,
+		"webdav": true,
+		"cifs":   true,
+	}
+)
+
+func hostMemoryStatus() (status *memoryStatus, err error) {
+	defer fail.Around(&err)
+
+	total, err := unix.SysctlUint64("hw.memsize")
+	fail.On(err != nil, "Could not get hw.memsize, reason: %v", err)
+	raw, err := unix.SysctlRaw("vm.swapusage")
+	fail.On(err != nil, "Could not get vm.swapusage, reason: %v", err)
+	fail.On(len(raw) < 24, "Too short vm.swapusage response, %d bytes", len(raw))
+
+	// macOS creates swap files on demand, so missing swap is not alarming
+	return &memoryStatus{
+		Total:     total,
+		SwapTotal: binary.LittleEndian.Uint64(raw[0:]),
+		SwapFree:  binary.LittleEndian.Uint64(raw[8:]),
+		SwapLabel: "swap",
+		NoSwap:    false,
+	}, nil
+}
+
+func hostEntropy() (*entropyStatus, error) {
+	// entropy pool is linux concept, macOS random device never blocks
+	return nil, nil
+}
+
+func hostMachineId() string {
+	command := []string{"ioreg", "-rd1", "-c", "IOPlatformExpertDevice"}
+	output, code, err := shell.New(nil, ".", command...).NoStderr().CaptureOutput()
+	if err != nil || code != 0 {
+		return ""
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, `"IOPlatformUUID"`) {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			return strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		}
+	}
+	return ""
+}
+
+// buildToolchain asks xcode-select for active developer directory, since
+// /usr/bin/clang and friends are shims, which open GUI install prompt when
+// Command Line Tools are missing, and must not be run before this check
+func buildToolchain() (location string, err error) {
+	defer fail.Around(&err)
+
+	output, code, err := shell.New(nil, ".", "xcode-select", "-p").NoStderr().CaptureOutput()
+	fail.On(err != nil, "Could not run xcode-select, reason: %v", err)
+	fail.On(code != 0, "Xcode Command Line Tools are not installed (xcode-select -p exited with code %d), install them with: xcode-select --install", code)
+	return strings.TrimSpace(output), nil
+}
+
+// quarantined tells if Gatekeeper quarantine attribute is set on file
+func quarantined(location string) bool {
+	size, err := unix.Getxattr(location, "com.apple.quarantine", nil)
+	return err == nil && size > 0
+}
+
+func hostCpuCounts() (present, usable int, err error) {
+	// macOS does not restrict processes with CPU affinity masks
+	return runtime.NumCPU(), runtime.NumCPU(), nil
+}
+
+// mountFlags lists restrictive mount flags of filesystem holding directory
+func mountFlags(directory string) string {
+	stat := unix.Statfs_t{}
+	if unix.Statfs(directory, &stat) != nil {
+		return ""
+	}
+	flags := []string{}
+	for _, flag := range restrictiveMounts {
+		if stat.Flags&flag.mask != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+	return strings.Join(flags, ",")
+}
+
+func networkFilesystem(directory string) (kind string, remote bool, err error) {
+	defer fail.Around(&err)
+
+	stat := unix.Statfs_t{}
+	err = unix.Statfs(directory, &stat)
+	fail.On(err != nil, "Could not stat filesystem of %q, reason: %v", directory, err)
+	kind = unix.ByteSliceToString(stat.Fstypename[:])
+	_, remote = networkFilesystems[kind]
+	return kind, remote, nil
+}
@@ -0,0 +1,163 @@
+package operations
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/robocorp/rcc/fail"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	restrictiveMounts = []struct {
+		mask int64
+		name string
+	}{
+		{unix.ST_RDONLY, "ro"},
+		{unix.ST_NOEXEC, "noexec"},
+		{unix.ST_NOSUID, "nosuid"},
+		{unix.ST_NODEV, "nodev"},
+	}
+	buildTools         = []string{"gcc", "g++", "make"}
+	networkFilesystems = map[int64]string{
+		0x6969:     "nfs",
+		0x517b:     "smb",
+		0xfe534d42: "smb2",
+		0xff534d42: "cifs",
+		0x5346414f: "afs",
+		0x73757245: "coda",
+		0x01021997: "9p",
+		0x564c:     "ncp",
+	}
+)
+
+func hostMemoryStatus() (status *memoryStatus, err error) {
+	defer fail.Around(&err)
+
+	file, err := os.Open("/proc/meminfo")
+	fail.On(err != nil, "Could not read memory information, reason: %v", err)
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = value * 1024
+	}
+	return &memoryStatus{
+		Total:     values["MemTotal"],
+		Available: values["MemAvailable"],
+		SwapTotal: values["SwapTotal"],
+		SwapFree:  values["SwapFree"],
+		SwapLabel: "swap",
+		NoSwap:    values["SwapTotal"] == 0,
+	}, nil
+}
+
+func hostEntropy() (entropy *entropyStatus, err error) {
+	defer fail.Around(&err)
+
+	content, err := os.ReadFile("/proc/sys/kernel/random/entropy_avail")
+	fail.On(err != nil, "Could not read entropy_avail, reason: %v", err)
+	available, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	fail.On(err != nil, "Could not parse entropy_avail, reason: %v", err)
+	name := unix.Utsname{}
+	err = unix.Uname(&name)
+	fail.On(err != nil, "Could not get kernel version, reason: %v", err)
+	return &entropyStatus{
+		Available: available,
+		Kernel:    unix.ByteSliceToString(name.Release[:]),
+	}, nil
+}
+
+func hostMachineId() string {
+	for _, candidate := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		content, err := os.ReadFile(candidate)
+		if err == nil && len(strings.TrimSpace(string(content))) > 0 {
+			return string(content)
+		}
+	}
+	return ""
+}
+
+func buildToolchain() (string, error) {
+	// on linux, toolchain is whatever compilers are found from PATH
+	return "", nil
+}
+
+func quarantined(location string) bool {
+	// quarantine is macOS concept
+	return false
+}
+
+func onlineCpuCount() int {
+	content, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	total := 0
+	for _, span := range strings.Split(strings.TrimSpace(string(content)), ",") {
+		limits := strings.SplitN(span, "-", 2)
+		first, err := strconv.Atoi(limits[0])
+		if err != nil {
+			return runtime.NumCPU()
+		}
+		last := first
+		if len(limits) > 1 {
+			last, err = strconv.Atoi(limits[1])
+			if err != nil {
+				return runtime.NumCPU()
+			}
+		}
+		total += last - first + 1
+	}
+	return total
+}
+
+func hostCpuCounts() (present, usable int, err error) {
+	defer fail.Around(&err)
+
+	affinity := unix.CPUSet{}
+	err = unix.SchedGetaffinity(0, &affinity)
+	fail.On(err != nil, "Could not get CPU affinity, reason: %v", err)
+	return onlineCpuCount(), affinity.Count(), nil
+}
+
+// mountFlags lists restrictive mount flags of filesystem holding directory
+func mountFlags(directory string) string {
+	stat := unix.Statfs_t{}
+	if unix.Statfs(directory, &stat) != nil {
+		return ""
+	}
+	flags := []string{}
+	for _, flag := range restrictiveMounts {
+		if stat.Flags&flag.mask != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+	return strings.Join(flags, ",")
+}
+
+func networkFilesystem(directory string) (kind string, remote bool, err error) {
+	defer fail.Around(&err)
+
+	stat := unix.Statfs_t{}
+	err = unix.Statfs(directory, &stat)
+	fail.On(err != nil, "Could not stat filesystem of %q, reason: %v", directory, err)
+	kind, remote = networkFilesystems[int64(stat.Type)]
+	if !remote {
+		kind = fmt.Sprintf("0x%x", stat.Type)
+	}
+	return kind, remote, nil
+}
@@ -0,0 +1,273 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestClockSkewFollowsThresholds(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	dated := func(shift time.Duration) *cloud.Response {
+		header := make(http.Header)
+		header.Set("Date", time.Now().Add(shift).UTC().Format(http.TimeFormat))
+		return &cloud.Response{Header: header}
+	}
+	must_be.Nil(clockSkewCheck(&cloud.Response{}))
+	must_be.Nil(clockSkewCheck(&cloud.Response{Header: make(http.Header)}))
+	wont_be.Nil(clockSkewCheck(dated(0)))
+	must_be.Equal(statusOk, clockSkewCheck(dated(0)).Status)
+	must_be.Equal(statusWarning, clockSkewCheck(dated(-2*time.Minute)).Status)
+	must_be.Equal(statusWarning, clockSkewCheck(dated(2*time.Minute)).Status)
+	must_be.Equal(statusFail, clockSkewCheck(dated(10*time.Minute)).Status)
+}
+
+func TestCanRecognizeCaptivePortalResponses(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	canary := &cloud.Response{Status: 200, Body: []byte("Used to testing connections"), Header: http.Header{"Content-Type": []string{"text/html"}}}
+	wont_be.True(looksLikeCaptivePortal(canary, "Used to testing connections"))
+	portal := &cloud.Response{Status: 200, Body: []byte("\n<!DOCTYPE html><html><body>Login</body></html>")}
+	must_be.True(looksLikeCaptivePortal(portal, "Used to testing connections"))
+	typed := &cloud.Response{Status: 200, Body: []byte("please login"), Header: http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}}
+	must_be.True(looksLikeCaptivePortal(typed, "Used to testing connections"))
+	other := &cloud.Response{Status: 200, Body: []byte("something else")}
+	wont_be.True(looksLikeCaptivePortal(other, "Used to testing connections"))
+}
+
+func TestCanSplitLinkIntoEndpointAndResource(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	endpoint, resource := splitLink("https://mirror.example.com:8443/rpa/canary.txt?x=1")
+	must_be.Equal("https://mirror.example.com:8443", endpoint)
+	must_be.Equal("/rpa/canary.txt?x=1", resource)
+	endpoint, resource = splitLink("https://downloads.robocorp.com")
+	must_be.Equal("https://downloads.robocorp.com", endpoint)
+	must_be.Equal("/", resource)
+}
+
+func TestStaleLocksNeedOldAgeAndLiveHolder(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	directory := t.TempDir()
+	fresh := filepath.Join(directory, "fresh.lck")
+	stale := filepath.Join(directory, "stale.lck")
+	must_be.Nil(os.WriteFile(fresh, []byte{}, 0o644))
+	must_be.Nil(os.WriteFile(stale, []byte{}, 0o644))
+	old := time.Now().Add(-3 * time.Hour)
+	must_be.Nil(os.Chtimes(stale, old, old))
+
+	nobody := func(string) (pathlib.Lockpids, error) { return pathlib.Lockpids{}, nil }
+	everybody := func(string) (pathlib.Lockpids, error) {
+		return pathlib.Lockpids{&pathlib.Lockpid{ProcessID: 4242}}, nil
+	}
+
+	checks := staleLocksCheck(directory, time.Hour, nobody)
+	must_be.Equal(1, len(checks))
+	must_be.Equal(statusOk, checks[0].Status)
+
+	checks = staleLocksCheck(directory, time.Hour, everybody)
+	must_be.Equal(1, len(checks))
+	must_be.Equal(statusWarning, checks[0].Status)
+	must_be.True(strings.Contains(checks[0].Message, stale))
+	must_be.True(strings.Contains(checks[0].Message, "3h0m0s"))
+	must_be.True(strings.Contains(checks[0].Message, "4242"))
+}
+
+func TestSinkMapsStatusToSeverity(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal("crit", syslogSeverity(statusFatal))
+	must_be.Equal("err", syslogSeverity(statusFail))
+	must_be.Equal("warning", syslogSeverity(statusWarning))
+	must_be.Equal("info", syslogSeverity(statusOk))
+
+	result := &common.DiagnosticStatus{
+		Checks: []*common.DiagnosticCheck{
+			{Type: "OS", Category: common.CategoryDiskSpace, Status: statusFail, Message: "disk is full"},
+			{Type: "network", Category: common.CategoryNetworkDNS, Status: statusOk, Message: "ok"},
+		},
+	}
+	sink := &bytes.Buffer{}
+	must_be.Nil(sinkDiagnostics(&lineSink{sink}, result))
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	must_be.Equal(2, len(lines))
+	must_be.True(strings.HasPrefix(lines[0], "<err> category="))
+	must_be.True(strings.Contains(lines[0], `message="disk is full"`))
+	must_be.True(strings.HasPrefix(lines[1], "<info> "))
+}
+
+func TestTimeZoneCheckWarnsWithoutZoneDatabase(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	t.Setenv("TZ", "")
+	zone := time.FixedZone("EET", 2*60*60)
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, zone)
+	loaded := func(string) (*time.Location, error) {
+		return zone, nil
+	}
+	missing := func(name string) (*time.Location, error) {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+
+	details := make(map[string]string)
+	check := timeZoneCheck(details, now, loaded)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal(uint64(common.CategoryTimeZone), check.Category)
+	must_be.Equal("+02:00", details["time-zone-utc-offset"])
+	must_be.Equal("EET (EET)", details["time-zone"])
+
+	if zoneDatabaseNeeded {
+		must_be.Equal(statusWarning, timeZoneCheck(details, now, missing).Status)
+	}
+
+	t.Setenv("TZ", "Mars/Olympus_Mons")
+	check = timeZoneCheck(details, now, missing)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, "Mars/Olympus_Mons"))
+}
+
+func TestEntropyCheckKnowsModernKernels(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	details := make(map[string]string)
+	must_be.Nil(entropyCheck(details, nil, nil))
+	must_be.Equal(statusWarning, entropyCheck(details, nil, fmt.Errorf("no proc")).Status)
+
+	must_be.Equal(statusWarning, entropyCheck(details, &entropyStatus{Available: 42, Kernel: "4.18.0-513.el8.x86_64"}, nil).Status)
+	must_be.Equal("42", details["entropy-available"])
+	must_be.Equal(statusOk, entropyCheck(details, &entropyStatus{Available: 3000, Kernel: "4.18.0"}, nil).Status)
+	must_be.Equal(statusOk, entropyCheck(details, &entropyStatus{Available: 42, Kernel: "5.6.0"}, nil).Status)
+	must_be.Equal(statusOk, entropyCheck(details, &entropyStatus{Available: 256, Kernel: "6.1.0-18-amd64"}, nil).Status)
+	must_be.Equal(uint64(common.CategoryEntropy), entropyCheck(details, &entropyStatus{Kernel: "6.1"}, nil).Category)
+}
+
+func TestHumaneOutputShowsRemediationCommands(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.Equal("nslookup example.com", nslookupCommand("example.com", ""))
+	must_be.Equal("nslookup example.com 8.8.8.8", nslookupCommand("example.com", "8.8.8.8"))
+
+	status := &common.DiagnosticStatus{
+		Details: map[string]string{},
+		Checks: []*common.DiagnosticCheck{
+			{Type: "network", Status: statusFail, Message: "DNS lookup failed", Command: "nslookup broken.example.com"},
+			{Type: "network", Status: statusOk, Message: "DNS lookup ok", Command: "nslookup fine.example.com"},
+		},
+	}
+	sink := &bytes.Buffer{}
+	humaneDiagnostics(sink, status, false)
+	must_be.True(strings.Contains(sink.String(), " run: "))
+	must_be.True(strings.Contains(sink.String(), " nslookup broken.example.com\n"))
+	wont_be.True(strings.Contains(sink.String(), "fine.example.com"))
+}
+
+func TestCanaryWrapperToleratesBomAndWhitespace(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	expected := "Used to testing connections"
+	wrapper, ok := canaryWrapper([]byte(expected+"\n"), expected)
+	must_be.True(ok)
+	must_be.Equal(`trailing "\n"`, wrapper)
+
+	wrapper, ok = canaryWrapper([]byte(utf8Bom+" "+expected), expected)
+	must_be.True(ok)
+	must_be.Equal(`UTF-8 BOM, leading " "`, wrapper)
+
+	_, ok = canaryWrapper([]byte("<html>"+expected+"</html>"), expected)
+	wont_be.True(ok)
+	_, ok = canaryWrapper([]byte(""), "")
+	wont_be.True(ok)
+
+	must_be.True(strings.HasPrefix(canaryDump([]byte(utf8Bom+"Used")), "00000000  ef bb bf 55 73 65 64"))
+}
+
+func TestReadinessIsBlockedByUnusableTempDirectory(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	checks := []*common.DiagnosticCheck{
+		&common.DiagnosticCheck{Category: common.CategoryDiskSpace, Status: statusOk},
+		&common.DiagnosticCheck{Category: common.CategoryTempDirectory, Status: statusFail},
+	}
+	readiness := readinessCheck(checks)
+	must_be.Equal(statusFail, readiness.Status)
+	must_be.True(strings.Contains(readiness.Message, "writable temporary directory"))
+
+	checks[1].Status = statusOk
+	must_be.Equal(statusOk, readinessCheck(checks).Status)
+}
+
+func TestEveryCheckHasCodeOfItsCategory(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	result := RunDiagnostics(&DiagnosticsOptions{Quick: true, Offline: true})
+	for _, check := range result.Checks {
+		must_be.Equal(check.Category, check.Code/10)
+	}
+}
+
+func TestHomeConflictNeedsBothVariablesToDiffer(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	t.Setenv("RCC_TEST_PROFILE", `C:\Users\robot`)
+	t.Setenv("RCC_TEST_HOME", "")
+	must_be.Nil(homeConflictCheck("RCC_TEST_PROFILE", "RCC_TEST_HOME"))
+	must_be.Nil(homeConflictCheck("RCC_TEST_PROFILE", ""))
+
+	t.Setenv("RCC_TEST_HOME", `H:\`)
+	check := homeConflictCheck("RCC_TEST_PROFILE", "RCC_TEST_HOME")
+	wont_be.Nil(check)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.Equal(uint64(common.CodeHomeVariablesDiffer), check.Code)
+
+	t.Setenv("RCC_TEST_HOME", `c:\users\robot`)
+	must_be.Equal(statusOk, homeConflictCheck("RCC_TEST_PROFILE", "RCC_TEST_HOME").Status)
+}
+
+func TestAnonymizeHidesUserAndHostIdentities(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	plain := RunDiagnostics(&DiagnosticsOptions{Quick: true, Offline: true})
+	hidden := RunDiagnostics(&DiagnosticsOptions{Quick: true, Offline: true, Anonymize: true})
+	for _, key := range []string{"user-home-dir", "hostname", "user-username"} {
+		original := plain.Details[key]
+		if len(original) == 0 {
+			continue
+		}
+		wont_be.Equal(original, hidden.Details[key])
+		must_be.True(strings.HasPrefix(hidden.Details[key], "anonymized-"))
+	}
+	home := plain.Details["user-home-dir"]
+	for _, value := range hidden.Details {
+		wont_be.True(len(home) > 1 && strings.Contains(value, home))
+	}
+}
+
+func TestDiagnosticsTempFileFollowsFormat(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	filename, err := diagnosticsTempFile(formatYaml)
+	must_be.Nil(err)
+	defer os.Remove(filename)
+	must_be.Equal(".yaml", filepath.Ext(filename))
+	wont_be.True(strings.Contains(filepath.Base(filename), "*"))
+}
+
+func TestStreamCannotBeCombinedWithOtherFormats(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	_, err := ProduceDiagnosticsFormat("", "", formatYaml, false, &DiagnosticsOptions{Stream: true})
+	wont_be.Nil(err)
+	must_be.True(strings.Contains(err.Error(), "--stream"))
+}
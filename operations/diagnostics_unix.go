@@ -0,0 +1,163 @@
+//go:build darwin || linux || !windows
+// +build darwin linux !windows
+
+package operations
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+	"syscall"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	homeVariable    = `HOME`
+	homeAlternative = ``
+	hostsFilename   = `/etc/hosts`
+	execProbeName   = `probe.sh`
+	execProbeScript = "#!/bin/sh\nexit 0\n"
+	// named zones need tzdata, which minimal containers often lack
+	zoneDatabaseNeeded = true
+	// long paths are always supported, so there is nothing to run
+	longPathCommand = ``
+)
+
+func hostsFile() string {
+	return hostsFilename
+}
+
+func configPermissionsCheck() []*common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	result := []*common.DiagnosticCheck{}
+	candidates := []string{
+		common.RobocorpHome(),
+		common.SettingsFile(),
+		common.CaBundleFile(),
+		common.PipRcFile(),
+		common.MicroMambaRcFile(),
+	}
+	for _, candidate := range candidates {
+		stat, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		mode := stat.Mode().Perm()
+		if mode&0o022 != 0 {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryConfigPermissions,
+				Code:     common.CodeConfigWritable,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("%q is writable by group or others (mode %04o). They could tamper trust and proxy configuration.", candidate, mode),
+				Link:     supportGeneralUrl,
+			})
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryConfigPermissions,
+			Code:     common.CodeConfigPermissionsOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("rcc configuration in %q is not writable by group or others.", common.RobocorpHome()),
+			Link:     supportGeneralUrl,
+		})
+	}
+	return result
+}
+
+func defenderExclusionCheck() []*common.DiagnosticCheck {
+	// windows defender only exists on windows
+	return []*common.DiagnosticCheck{}
+}
+
+func sharedPermissionsChecks(paths []string) []*common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	result := []*common.DiagnosticCheck{}
+	for _, fullpath := range paths {
+		stat, err := os.Stat(fullpath)
+		if err != nil {
+			continue
+		}
+		mode := stat.Mode().Perm()
+		owner := "unknown"
+		system, ok := stat.Sys().(*syscall.Stat_t)
+		if ok {
+			owner = fmt.Sprintf("uid %d, gid %d", system.Uid, system.Gid)
+		}
+		switch {
+		case mode&0o005 == 0o005:
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryHolotreeSharedMode,
+				Code:     common.CodeSharedModeOk,
+				Status:   statusOk,
+				Message:  fmt.Sprintf("Shared %q (mode %04o, %s) is readable by all users.", fullpath, mode, owner),
+				Link:     supportGeneralUrl,
+			})
+		case mode&0o050 == 0o050:
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryHolotreeSharedMode,
+				Code:     common.CodeSharedModeGroupOnly,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Shared %q (mode %04o, %s) is readable only by group members. Other users cannot use shared holotree.", fullpath, mode, owner),
+				Link:     supportGeneralUrl,
+			})
+		default:
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "OS",
+				Category: common.CategoryHolotreeSharedMode,
+				Code:     common.CodeSharedModeUnreadable,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Shared %q (mode %04o, %s) is not readable by other users. Sharing will not work.", fullpath, mode, owner),
+				Link:     supportGeneralUrl,
+			})
+		}
+	}
+	return result
+}
+
+func hostDiskSpace(directory string) (free, total uint64, err error) {
+	defer fail.Around(&err)
+
+	stat := unix.Statfs_t{}
+	err = unix.Statfs(directory, &stat)
+	fail.On(err != nil, "Could not stat filesystem of %q, reason: %v", directory, err)
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
+
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func syslogIt(tag string) (checkSink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer}, nil
+}
+
+func (it *syslogSink) Emit(check *common.DiagnosticCheck) error {
+	line := syslogLine(check)
+	switch syslogSeverity(check.Status) {
+	case "crit":
+		return it.writer.Crit(line)
+	case "err":
+		return it.writer.Err(line)
+	case "warning":
+		return it.writer.Warning(line)
+	default:
+		return it.writer.Info(line)
+	}
+}
+
+func (it *syslogSink) Close() error {
+	return it.writer.Close()
+}
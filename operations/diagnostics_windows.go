@@ -0,0 +1,273 @@
+//go:build windows
+// +build windows
+
+package operations
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+	"github.com/robocorp/rcc/shell"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	homeVariable    = `USERPROFILE`
+	homeAlternative = `HOME`
+	execProbeName   = `probe.bat`
+	execProbeScript = "@exit /b 0\r\n"
+	// local zone comes from registry, and Go zone database is not needed
+	zoneDatabaseNeeded = false
+
+	allProcessorGroups = 0xffff
+	driveRemote        = 4
+
+	defenderExclusions = `(Get-MpPreference).ExclusionPath`
+	longPathCommand    = `New-ItemProperty -Path "HKLM:\SYSTEM\CurrentControlSet\Control\FileSystem" -Name "LongPathsEnabled" -Value 1 -PropertyType DWORD -Force`
+)
+
+func hostsFile() string {
+	root := os.Getenv("SystemRoot")
+	if len(root) == 0 {
+		root = `C:\Windows`
+	}
+	return filepath.Join(root, `System32\drivers\etc\hosts`)
+}
+
+func configPermissionsCheck() []*common.DiagnosticCheck {
+	// unix style permission bits are not meaningful on windows
+	return []*common.DiagnosticCheck{}
+}
+
+func sharedPermissionsChecks(paths []string) []*common.DiagnosticCheck {
+	// sharing on windows is based on ACLs, not on permission bits
+	return []*common.DiagnosticCheck{}
+}
+
+func defenderExclusionPaths() ([]string, error) {
+	command := []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", defenderExclusions}
+	output, code, err := shell.New(nil, ".", command...).NoStderr().CaptureOutput()
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("powershell exited with code %d", code)
+	}
+	result := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "N/A") {
+			return nil, fmt.Errorf("%s", line)
+		}
+		result = append(result, line)
+	}
+	return result, nil
+}
+
+func defenderExclusionCheck() []*common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	home := common.RobocorpHome()
+	exclusions, err := defenderExclusionPaths()
+	if err != nil {
+		return []*common.DiagnosticCheck{&common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryAntivirus,
+			Code:     common.CodeAntivirusUnknown,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Could not query Windows Defender exclusions (%v), so cannot tell if ROBOCORP_HOME %q is scanned.", err, home),
+			Link:     supportGeneralUrl,
+		}}
+	}
+	exclusion, ok := excludedLocation(home, exclusions)
+	if !ok {
+		return []*common.DiagnosticCheck{&common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryAntivirus,
+			Code:     common.CodeAntivirusNotExcluded,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("ROBOCORP_HOME %q is not excluded from Windows Defender real-time scanning, which slows down environment creation and may quarantine micromamba. Ask your administrator to add it as exclusion, for example: Add-MpPreference -ExclusionPath %q", home, home),
+			Link:     supportGeneralUrl,
+		}}
+	}
+	return []*common.DiagnosticCheck{&common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryAntivirus,
+		Code:     common.CodeAntivirusExcluded,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("ROBOCORP_HOME %q is excluded from Windows Defender scanning by %q.", home, exclusion),
+		Link:     supportGeneralUrl,
+	}}
+}
+
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+var (
+	// cl, link and nmake are only on PATH inside developer prompt, so
+	// Visual Studio installation is detected by buildToolchain instead
+	buildTools      = []string{}
+	visualStudioKey = []string{`SOFTWARE\Microsoft\VisualStudio\SxS\VC7`, `SOFTWARE\Microsoft\VisualStudio\SxS\VS7`}
+)
+
+func hostMemoryStatus() (status *memoryStatus, err error) {
+	defer fail.Around(&err)
+
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	globalMemoryStatusEx := kernel32.NewProc("GlobalMemoryStatusEx")
+	fail.On(globalMemoryStatusEx.Find() != nil, "Could not find GlobalMemoryStatusEx from kernel32.dll")
+
+	memory := memoryStatusEx{}
+	memory.Length = uint32(unsafe.Sizeof(memory))
+	success, _, err := globalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&memory)))
+	fail.On(success == 0, "GlobalMemoryStatusEx failed, reason: %v", err)
+
+	// on windows, "swap" is commit limit and available commit charge
+	return &memoryStatus{
+		Total:     memory.TotalPhys,
+		Available: memory.AvailPhys,
+		SwapTotal: memory.TotalPageFile,
+		SwapFree:  memory.AvailPageFile,
+		SwapLabel: "commit",
+		NoSwap:    memory.TotalPageFile <= memory.TotalPhys,
+	}, nil
+}
+
+func hostEntropy() (*entropyStatus, error) {
+	// entropy pool is linux concept, windows CNG never blocks
+	return nil, nil
+}
+
+func hostMachineId() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	guid, _, err := key.GetStringValue("MachineGuid")
+	if err != nil {
+		return ""
+	}
+	return guid
+}
+
+// buildToolchain finds Visual Studio installation with C++ tools, first using
+// vswhere (VS 2017 and newer), and then legacy registry keys
+func buildToolchain() (location string, err error) {
+	defer fail.Around(&err)
+
+	root := os.Getenv("ProgramFiles(x86)")
+	if len(root) == 0 {
+		root = `C:\Program Files (x86)`
+	}
+	vswhere := filepath.Join(root, `Microsoft Visual Studio\Installer\vswhere.exe`)
+	command := []string{vswhere, "-latest", "-products", "*", "-requires", "Microsoft.VisualStudio.Component.VC.Tools.x86.x64", "-property", "installationPath"}
+	output, code, err := shell.New(nil, ".", command...).NoStderr().CaptureOutput()
+	if err == nil && code == 0 && len(strings.TrimSpace(output)) > 0 {
+		return strings.TrimSpace(strings.SplitN(output, "\n", 2)[0]), nil
+	}
+	for _, name := range visualStudioKey {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, name, registry.QUERY_VALUE|registry.WOW64_32KEY)
+		if err != nil {
+			continue
+		}
+		values, _ := key.ReadValueNames(0)
+		for _, value := range values {
+			location, _, err := key.GetStringValue(value)
+			if err == nil && len(location) > 0 {
+				key.Close()
+				return location, nil
+			}
+		}
+		key.Close()
+	}
+	return "", fmt.Errorf("No Visual Studio with C++ build tools found (neither vswhere nor registry knows one)")
+}
+
+func quarantined(location string) bool {
+	// quarantine is macOS concept
+	return false
+}
+
+func hostCpuCounts() (present, usable int, err error) {
+	defer fail.Around(&err)
+
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getActiveProcessorCount := kernel32.NewProc("GetActiveProcessorCount")
+	fail.On(getActiveProcessorCount.Find() != nil, "Could not find GetActiveProcessorCount from kernel32.dll")
+	getProcessAffinityMask := kernel32.NewProc("GetProcessAffinityMask")
+	fail.On(getProcessAffinityMask.Find() != nil, "Could not find GetProcessAffinityMask from kernel32.dll")
+
+	count, _, _ := getActiveProcessorCount.Call(uintptr(allProcessorGroups))
+	fail.On(count == 0, "GetActiveProcessorCount failed")
+
+	process, err := syscall.GetCurrentProcess()
+	fail.On(err != nil, "Could not get current process handle, reason: %v", err)
+	processMask, systemMask := uintptr(0), uintptr(0)
+	success, _, err := getProcessAffinityMask.Call(uintptr(process), uintptr(unsafe.Pointer(&processMask)), uintptr(unsafe.Pointer(&systemMask)))
+	fail.On(success == 0, "GetProcessAffinityMask failed, reason: %v", err)
+	return int(count), bits.OnesCount64(uint64(processMask)), nil
+}
+
+func hostDiskSpace(directory string) (free, total uint64, err error) {
+	defer fail.Around(&err)
+
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getDiskFreeSpaceEx := kernel32.NewProc("GetDiskFreeSpaceExW")
+	fail.On(getDiskFreeSpaceEx.Find() != nil, "Could not find GetDiskFreeSpaceExW from kernel32.dll")
+
+	location, err := syscall.UTF16PtrFromString(directory)
+	fail.On(err != nil, "Could not convert %q, reason: %v", directory, err)
+	success, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(location)), uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), 0)
+	fail.On(success == 0, "GetDiskFreeSpaceExW failed for %q, reason: %v", directory, err)
+	return free, total, nil
+}
+
+func mountFlags(directory string) string {
+	// windows has no mount flags, execution is blocked by policies instead
+	return ""
+}
+
+func networkFilesystem(directory string) (kind string, remote bool, err error) {
+	defer fail.Around(&err)
+
+	if strings.HasPrefix(directory, `\\`) || strings.HasPrefix(directory, `//`) {
+		return "UNC path", true, nil
+	}
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getDriveType := kernel32.NewProc("GetDriveTypeW")
+	fail.On(getDriveType.Find() != nil, "Could not find GetDriveTypeW from kernel32.dll")
+
+	root, err := syscall.UTF16PtrFromString(filepath.VolumeName(directory) + `\`)
+	fail.On(err != nil, "Could not convert %q, reason: %v", directory, err)
+	driveType, _, _ := getDriveType.Call(uintptr(unsafe.Pointer(root)))
+	if driveType == driveRemote {
+		return "mapped network drive", true, nil
+	}
+	return fmt.Sprintf("drive type %d", driveType), false, nil
+}
+
+func syslogIt(tag string) (checkSink, error) {
+	// there is no syslog on windows, so lines go to stderr instead
+	common.Log("Note: no syslog on windows, writing %s diagnostics to stderr.", tag)
+	return &lineSink{os.Stderr}, nil
+}
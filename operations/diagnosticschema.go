@@ -0,0 +1,181 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/robocorp/rcc/blobs"
+)
+
+const (
+	diagnosticsSchemaAsset = "assets/diagnostics_schema.json"
+)
+
+// jsonSchema is just that subset of JSON schema, which diagnostics schema
+// uses: types, enums, minimums, required and known properties, and local
+// references to definitions. Any other keyword is rejected when parsing,
+// instead of being silently ignored.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema"`
+	Title                string                 `json:"title"`
+	Description          string                 `json:"description"`
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+func parseJsonSchema(raw []byte) (*jsonSchema, error) {
+	schema := &jsonSchema{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(schema)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported or invalid schema: %v", err)
+	}
+	return schema, nil
+}
+
+func (it *jsonSchema) resolve(root *jsonSchema) (*jsonSchema, error) {
+	if len(it.Ref) == 0 {
+		return it, nil
+	}
+	name := strings.TrimPrefix(it.Ref, "#/$defs/")
+	found, ok := root.Defs[name]
+	if !ok || name == it.Ref {
+		return nil, fmt.Errorf("unsupported schema reference %q", it.Ref)
+	}
+	return found, nil
+}
+
+// additional tells if unlisted properties are allowed; in strict mode, only
+// explicit additionalProperties allows them
+func (it *jsonSchema) additional(strict bool) (allowed bool, schema *jsonSchema, err error) {
+	raw := bytes.TrimSpace(it.AdditionalProperties)
+	if len(raw) == 0 {
+		return !strict, nil, nil
+	}
+	if bytes.Equal(raw, []byte("true")) {
+		return true, nil, nil
+	}
+	if bytes.Equal(raw, []byte("false")) {
+		return false, nil, nil
+	}
+	schema, err = parseJsonSchema(raw)
+	return true, schema, err
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch actual := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if actual == math.Trunc(actual) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (it *jsonSchema) validate(root *jsonSchema, value interface{}, path string, strict bool) error {
+	schema, err := it.resolve(root)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	kind := jsonTypeOf(value)
+	if len(schema.Type) > 0 && schema.Type != kind && !(schema.Type == "number" && kind == "integer") {
+		return fmt.Errorf("%s: expected %s, but got %s", path, schema.Type, kind)
+	}
+	if len(schema.Enum) > 0 {
+		known := false
+		for _, candidate := range schema.Enum {
+			known = known || candidate == value
+		}
+		if !known {
+			return fmt.Errorf("%s: value %v is not one of %v", path, value, schema.Enum)
+		}
+	}
+	if number, ok := value.(float64); ok && schema.Minimum != nil && number < *schema.Minimum {
+		return fmt.Errorf("%s: value %v is below minimum %v", path, number, *schema.Minimum)
+	}
+	if items, ok := value.([]interface{}); ok && schema.Items != nil {
+		for at, item := range items {
+			err = schema.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, at), strict)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	members, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, name := range schema.Required {
+		if _, ok := members[name]; !ok {
+			return fmt.Errorf("%s: required property %q is missing", path, name)
+		}
+	}
+	allowed, extra, err := schema.additional(strict)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for name, member := range members {
+		where := fmt.Sprintf("%s.%s", path, name)
+		known, ok := schema.Properties[name]
+		switch {
+		case ok:
+			err = known.validate(root, member, where, strict)
+		case !allowed:
+			err = fmt.Errorf("%s: property is not allowed by schema", where)
+		case extra != nil:
+			err = extra.validate(root, member, where, strict)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateDiagnosticsJSON checks that content matches embedded JSON schema
+// of diagnostics output, as consumers see it: added optional properties are
+// allowed, since they do not bump schema version.
+func ValidateDiagnosticsJSON(content []byte) error {
+	return validateDiagnostics(content, false)
+}
+
+// validateDiagnostics in strict mode also rejects properties, which schema
+// does not list, so that accidental shape changes get noticed in tests.
+func validateDiagnostics(content []byte, strict bool) error {
+	schema, err := parseJsonSchema(blobs.MustAsset(diagnosticsSchemaAsset))
+	if err != nil {
+		return fmt.Errorf("Could not parse diagnostics schema, reason: %v", err)
+	}
+	var document interface{}
+	err = json.Unmarshal(content, &document)
+	if err != nil {
+		return fmt.Errorf("Diagnostics output is not valid JSON, reason: %v", err)
+	}
+	err = schema.validate(schema, document, "$", strict)
+	if err != nil {
+		return fmt.Errorf("Diagnostics output does not match schema, reason: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestDiagnosticsOutputMatchesSchema(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	result := RunDiagnostics(&DiagnosticsOptions{Quick: true, Offline: true})
+	result.Readiness = readinessCheck(result.Checks)
+	result.NextSteps = result.Remediations()
+	body, err := result.AsJson()
+	must_be.Nil(err)
+	must_be.Nil(validateDiagnostics([]byte(body), true))
+
+	line, err := result.AsJsonLine()
+	must_be.Nil(err)
+	must_be.Nil(validateDiagnostics([]byte(line), true))
+
+	wont_be.Nil(ValidateDiagnosticsJSON([]byte(`{"schema": 1, "details": {}}`)))
+	extraTop := []byte(`{"schema": 1, "details": {}, "checks": [], "extra": true}`)
+	extraCheck := []byte(`{"schema": 1, "details": {}, "checks": [{"type": "OS", "category": 1, "code": 1, "status": "ok", "message": "", "url": "", "extra": 1}]}`)
+	must_be.Nil(ValidateDiagnosticsJSON(extraTop))
+	must_be.Nil(ValidateDiagnosticsJSON(extraCheck))
+	wont_be.Nil(validateDiagnostics(extraTop, true))
+	wont_be.Nil(validateDiagnostics(extraCheck, true))
+	must_be.Nil(validateDiagnostics([]byte(`{"schema": 1, "details": {"cpus": "8"}, "checks": []}`), true))
+	wont_be.Nil(ValidateDiagnosticsJSON([]byte(`{"schema": 1, "details": {"cpus": 8}, "checks": []}`)))
+	wont_be.Nil(ValidateDiagnosticsJSON([]byte(`{"schema": 1, "details": {}, "checks": [{"type": "OS", "category": 1, "code": 1, "status": "bad", "message": "", "url": ""}]}`)))
+	wont_be.Nil(ValidateDiagnosticsJSON([]byte(`not json`)))
+}
+
+func TestSchemaParserRejectsUnknownKeywords(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	_, err := parseJsonSchema([]byte(`{"title": "ok", "type": "object", "properties": {"name": {"type": "string"}}}`))
+	must_be.Nil(err)
+	_, err = parseJsonSchema([]byte(`{"type": "object", "properties": {"name": {"type": "string", "pattern": "^a"}}}`))
+	wont_be.Nil(err)
+
+	schema, err := parseJsonSchema([]byte(`{"type": "object", "additionalProperties": {"type": "string", "maxLength": 3}}`))
+	must_be.Nil(err)
+	wont_be.Nil(schema.validate(schema, map[string]interface{}{"name": "abcd"}, "$", false))
+}
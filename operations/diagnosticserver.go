@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+)
+
+const (
+	serveAddressDefault = `127.0.0.1`
+)
+
+// healthCache keeps rendered diagnostics for ttl, so that frequent probes
+// (like kubernetes liveness and readiness) do not rerun network checks;
+// stale results are refreshed in background, so probes never wait for run
+type healthCache struct {
+	sync.Mutex
+	ttl        time.Duration
+	run        func() *common.DiagnosticStatus
+	stamp      time.Time
+	body       string
+	status     int
+	refreshing bool
+}
+
+func (it *healthCache) refresh() {
+	result := it.run()
+	body, err := result.AsJson()
+	status := http.StatusOK
+	if err != nil {
+		body, status = fmt.Sprintf("{\"error\": %q}", err.Error()), http.StatusInternalServerError
+	} else if fatal, fail, _, _ := result.Counts(); fatal+fail > 0 {
+		status = http.StatusServiceUnavailable
+	}
+	it.Lock()
+	defer it.Unlock()
+	it.status, it.body, it.stamp, it.refreshing = status, body, time.Now(), false
+}
+
+// current returns last cached result (or 503 before first run has finished)
+// and starts background refresh, when that result is stale
+func (it *healthCache) current() (int, string) {
+	it.Lock()
+	defer it.Unlock()
+	if !it.refreshing && (it.status == 0 || time.Since(it.stamp) >= it.ttl) {
+		it.refreshing = true
+		go it.refresh()
+	}
+	if it.status == 0 {
+		return http.StatusServiceUnavailable, `{"status": "diagnostics are still running"}`
+	}
+	return it.status, it.body
+}
+
+func (it *healthCache) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status, body := it.current()
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	if request.Method == http.MethodGet {
+		fmt.Fprintln(response, body)
+	}
+}
+
+// ServeDiagnostics serves diagnostics results as JSON over HTTP until
+// signaled, with status 200 when no check failed, and 503 otherwise.
+// Results contain user and host details, so default address is loopback.
+func ServeDiagnostics(address string, port int, ttl time.Duration, options *DiagnosticsOptions) error {
+	if len(address) == 0 {
+		address = serveAddressDefault
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(address, fmt.Sprintf("%d", port)))
+	if err != nil {
+		return err
+	}
+	cache := &healthCache{
+		ttl: ttl,
+		run: func() *common.DiagnosticStatus {
+			return RunDiagnosticsWith(nil, *options)
+		},
+	}
+	cache.current()
+	server := &http.Server{
+		Handler:        cache,
+		ReadTimeout:    30 * time.Second,
+		WriteTimeout:   30 * time.Second,
+		MaxHeaderBytes: 1 << 14,
+	}
+	common.Log("Serving diagnostics on http://%s/ (results refreshed every %s).", listener.Addr(), ttl)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.Serve(listener)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
+	select {
+	case err = <-failed:
+		return err
+	case <-signals:
+		return server.Shutdown(context.TODO())
+	}
+}
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestHealthEndpointCachesAndReportsFailures(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	runs := make(chan string, 10)
+	status := statusOk
+	cache := &healthCache{
+		ttl: time.Hour,
+		run: func() *common.DiagnosticStatus {
+			defer func() { runs <- status }()
+			return &common.DiagnosticStatus{
+				Details: map[string]string{},
+				Checks:  []*common.DiagnosticCheck{{Type: "OS", Category: common.CategoryDiskSpace, Status: status}},
+			}
+		},
+	}
+	server := httptest.NewServer(cache)
+	defer server.Close()
+
+	probe := func() int {
+		response, err := http.Get(server.URL)
+		must_be.Nil(err)
+		response.Body.Close()
+		return response.StatusCode
+	}
+	settled := func(expected int) int {
+		deadline := time.Now().Add(5 * time.Second)
+		for probe() != expected && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return probe()
+	}
+
+	must_be.Equal(http.StatusServiceUnavailable, probe())
+	must_be.Equal(statusOk, <-runs)
+	must_be.Equal(http.StatusOK, settled(http.StatusOK))
+
+	status = statusFail
+	must_be.Equal(http.StatusOK, probe())
+	must_be.Equal(0, len(runs))
+
+	cache.Lock()
+	cache.ttl = 0
+	cache.Unlock()
+	must_be.Equal(http.StatusOK, probe())
+	must_be.Equal(statusFail, <-runs)
+	must_be.Equal(http.StatusServiceUnavailable, settled(http.StatusServiceUnavailable))
+}
@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	diskSpaceWarning = 2 * 1024 * 1024 * 1024
+	diskSpaceFail    = 500 * 1024 * 1024
+)
+
+func gigabytes(value uint64) string {
+	return fmt.Sprintf("%.1f", float64(value)/(1024*1024*1024))
+}
+
+// existingAncestor is needed, since temp directories may not yet exist
+func existingAncestor(location string) string {
+	current := filepath.Clean(location)
+	for {
+		_, err := os.Stat(current)
+		parent := filepath.Dir(current)
+		if err == nil || parent == current {
+			return current
+		}
+		current = parent
+	}
+}
+
+func diskSpaceCheck(label, detail, location string, details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	free, total, err := hostDiskSpace(existingAncestor(location))
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryDiskSpace,
+			Code:     common.CodeDiskSpaceUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not get free disk space of %s %q, reason: %v", label, location, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details[detail] = gigabytes(free)
+	status := statusOk
+	switch {
+	case free < diskSpaceFail:
+		status = statusFail
+	case free < diskSpaceWarning:
+		status = statusWarning
+	}
+	if status != statusOk {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryDiskSpace,
+			Code:     common.CodeDiskSpaceLow,
+			Status:   status,
+			Message:  fmt.Sprintf("Low disk space for %s %q: %sGB free of %sGB total. Environment builds may fail.", label, location, gigabytes(free), gigabytes(total)),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryDiskSpace,
+		Code:     common.CodeDiskSpaceOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Disk space for %s %q: %sGB free of %sGB total.", label, location, gigabytes(free), gigabytes(total)),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func robocorpHomeNetworkCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	home := common.RobocorpHome()
+	kind, remote, err := networkFilesystem(existingAncestor(home))
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryRobocorpHomeNetwork,
+			Code:     common.CodeHomeFilesystemUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not determine filesystem type of ROBOCORP_HOME %q, reason: %v", home, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if remote {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryRobocorpHomeNetwork,
+			Code:     common.CodeHomeOnNetwork,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("ROBOCORP_HOME %q is on network filesystem [%s]. Holotree locking and symlinks may fail there; use local disk instead.", home, kind),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryRobocorpHomeNetwork,
+		Code:     common.CodeHomeFilesystemLocal,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("ROBOCORP_HOME %q is on local filesystem [%s].", home, kind),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func diskSpaceChecks(details map[string]string) []*common.DiagnosticCheck {
+	return []*common.DiagnosticCheck{
+		diskSpaceCheck("ROBOCORP_HOME", "disk-free-gb-robocorp-home", common.RobocorpHome(), details),
+		diskSpaceCheck("temp directory", "disk-free-gb-temp", effectiveTempDirectory(), details),
+	}
+}
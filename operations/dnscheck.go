@@ -0,0 +1,302 @@
+package operations
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	resolvConf       = `/etc/resolv.conf`
+	dnsTypeA         = 1
+	dnsClassIN       = 1
+	dnsTruncated     = 0x0200
+	dnsLowTtlSeconds = 10
+	dnsTtlSampleGap  = 2 * time.Second
+)
+
+// dnsServerAddress adds default port 53 to DNS server, when it has none
+func dnsServerAddress(server string) string {
+	server = strings.TrimSpace(server)
+	if _, _, err := net.SplitHostPort(server); len(server) > 0 && err != nil {
+		return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	return server
+}
+
+// dnsResolver returns system resolver when server is empty, and otherwise
+// resolver which sends all queries to server (port defaults to 53)
+func dnsResolver(server string) (*net.Resolver, string) {
+	server = dnsServerAddress(server)
+	if len(server) == 0 {
+		return net.DefaultResolver, "system resolver"
+	}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer := net.Dialer{}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+	return resolver, fmt.Sprintf("resolver %s", server)
+}
+
+func systemNameserver() string {
+	file, err := os.Open(resolvConf)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return ""
+}
+
+func dnsQuestion(identity uint16, host string) []byte {
+	query := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(query[0:], identity)
+	binary.BigEndian.PutUint16(query[2:], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(query[4:], 1)
+	for _, label := range strings.Split(strings.Trim(host, "."), ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, 0, dnsTypeA, 0, dnsClassIN)
+	return query
+}
+
+func skipDnsName(message []byte, offset int) (int, bool) {
+	for offset < len(message) {
+		size := int(message[offset])
+		switch {
+		case size == 0:
+			return offset + 1, true
+		case size&0xc0 == 0xc0:
+			return offset + 2, offset+2 <= len(message)
+		default:
+			offset += size + 1
+		}
+	}
+	return offset, false
+}
+
+func dnsAnswerTtl(identity uint16, message []byte) (ttl uint32, err error) {
+	defer fail.Around(&err)
+
+	fail.On(len(message) < 12, "DNS response too short, only %d bytes", len(message))
+	fail.On(binary.BigEndian.Uint16(message[0:]) != identity, "DNS response identity mismatch")
+	rcode := binary.BigEndian.Uint16(message[2:]) & 0x000f
+	fail.On(rcode != 0, "DNS response code was %d", rcode)
+	questions := int(binary.BigEndian.Uint16(message[4:]))
+	answers := int(binary.BigEndian.Uint16(message[6:]))
+	offset, ok := 12, true
+	for at := 0; at < questions; at++ {
+		offset, ok = skipDnsName(message, offset)
+		fail.On(!ok, "DNS response question section is broken")
+		offset += 4
+	}
+	found := false
+	for at := 0; at < answers; at++ {
+		offset, ok = skipDnsName(message, offset)
+		fail.On(!ok || offset+10 > len(message), "DNS response answer section is broken")
+		kind := binary.BigEndian.Uint16(message[offset:])
+		seconds := binary.BigEndian.Uint32(message[offset+4:])
+		length := int(binary.BigEndian.Uint16(message[offset+8:]))
+		offset += 10 + length
+		if kind == dnsTypeA && (!found || seconds < ttl) {
+			ttl, found = seconds, true
+		}
+	}
+	fail.On(!found, "DNS response did not contain any A records")
+	return ttl, nil
+}
+
+// dnsExchange sends query to server and returns response; over TCP message
+// is prefixed with its two byte length, as DNS requires
+func dnsExchange(network, server string, query []byte, timeout time.Duration) (response []byte, err error) {
+	defer fail.Around(&err)
+
+	connection, err := net.DialTimeout(network, server, timeout)
+	fail.On(err != nil, "Could not connect DNS server %q over %s, reason: %v", server, network, err)
+	defer connection.Close()
+	connection.SetDeadline(time.Now().Add(timeout))
+
+	stream := strings.HasPrefix(network, "tcp")
+	if stream {
+		query = append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)
+	}
+	_, err = connection.Write(query)
+	fail.On(err != nil, "Could not send DNS query to %q, reason: %v", server, err)
+
+	if !stream {
+		buffer := make([]byte, 1500)
+		size, err := connection.Read(buffer)
+		fail.On(err != nil, "Could not read DNS response from %q, reason: %v", server, err)
+		return buffer[:size], nil
+	}
+	prefix := make([]byte, 2)
+	_, err = io.ReadFull(connection, prefix)
+	fail.On(err != nil, "Could not read DNS response from %q, reason: %v", server, err)
+	response = make([]byte, binary.BigEndian.Uint16(prefix))
+	_, err = io.ReadFull(connection, response)
+	fail.On(err != nil, "Could not read DNS response from %q, reason: %v", server, err)
+	return response, nil
+}
+
+// dnsQueryTtl asks A record TTL of host over UDP, and retries over TCP, if
+// response was truncated; family suffix (like "4") comes from ip-family
+func dnsQueryTtl(server, host, family string, timeout time.Duration) (ttl uint32, err error) {
+	identity := uint16(rand.Uint32())
+	query := dnsQuestion(identity, host)
+	response, err := dnsExchange("udp"+family, server, query, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if len(response) > 3 && binary.BigEndian.Uint16(response[2:])&dnsTruncated != 0 {
+		response, err = dnsExchange("tcp"+family, server, query, timeout)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return dnsAnswerTtl(identity, response)
+}
+
+// dnsTtlCheck asks TTL of downloads host twice, gap apart, from given DNS
+// server or from system one; caching resolvers count TTL down (or refetch
+// full TTL), but intercepting resolvers tend to answer same low TTL always
+func dnsTtlCheck(dnsServer string, gap, timeout time.Duration) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	parsed, err := url.Parse(settings.Global.DownloadsLink(""))
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSTTL,
+			Code:     common.CodeDnsTtlInvalidLink,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not parse downloads link for DNS TTL check, reason: %v", err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	host := parsed.Hostname()
+	server := dnsServerAddress(dnsServer)
+	if len(server) == 0 {
+		server = systemNameserver()
+	}
+	if len(server) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSTTL,
+			Code:     common.CodeDnsTtlUnsupported,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("DNS TTL check is not supported here, since system DNS server could not be found; DNS TTL of %q was not checked. Use --dns-server to check specific server.", host),
+			Link:     supportNetworkUrl,
+		}
+	}
+	family := strings.TrimPrefix(settings.Global.IpNetwork(), "ip")
+	first, err := dnsQueryTtl(server, host, family, timeout)
+	second := first
+	if err == nil {
+		time.Sleep(gap)
+		second, err = dnsQueryTtl(server, host, family, timeout)
+	}
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSTTL,
+			Code:     common.CodeDnsTtlQueryFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("DNS TTL query of %q via %s failed, reason: %v", host, server, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if first < dnsLowTtlSeconds && second < dnsLowTtlSeconds && second >= first {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkDNSTTL,
+			Code:     common.CodeDnsTtlLow,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("DNS TTL of %q via %s stays suspiciously low (%d and %d seconds, %s apart) instead of counting down. This may indicate intercepting resolver.", host, server, first, second, gap),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkDNSTTL,
+		Code:     common.CodeDnsTtlOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("DNS TTL of %q via %s is %d seconds (%d seconds %s later).", host, server, first, second, gap),
+		Link:     supportNetworkUrl,
+	}
+}
+
+// parseHostsFile maps lowercase hostnames to addresses statically given in
+// hosts file content (comments and malformed lines are ignored)
+func parseHostsFile(content string) map[string][]string {
+	result := make(map[string][]string)
+	for _, line := range strings.Split(content, "\n") {
+		if at := strings.Index(line, "#"); at >= 0 {
+			line = line[:at]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			result[name] = append(result[name], fields[0])
+		}
+	}
+	return result
+}
+
+func hostsFileChecks(filename string, hosts []string) []*common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	static := parseHostsFile(string(content))
+	result := []*common.DiagnosticCheck{}
+	for _, host := range hosts {
+		addresses, ok := static[strings.ToLower(host)]
+		if !ok {
+			continue
+		}
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkHostsFile,
+			Code:     common.CodeHostsFileOverride,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Host %q is statically mapped to %s in hosts file %q, which overrides DNS.", host, strings.Join(addresses, ", "), filename),
+			Link:     supportNetworkUrl,
+		})
+	}
+	if len(result) > 0 {
+		return result
+	}
+	return []*common.DiagnosticCheck{{
+		Type:     "network",
+		Category: common.CategoryNetworkHostsFile,
+		Code:     common.CodeHostsFileOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("None of %d diagnostics hosts are overridden in hosts file %q.", len(hosts), filename),
+		Link:     supportNetworkUrl,
+	}}
+}
@@ -0,0 +1,174 @@
+package operations
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestDnsTtlCheckComparesTwoAnswers(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	check := dnsTtlCheck(fakeDnsServer(t, 300, 298), 0, time.Second)
+	must_be.Equal(statusOk, check.Status)
+	must_be.True(strings.Contains(check.Message, "is 300 seconds"))
+
+	check = dnsTtlCheck(fakeDnsServer(t, 5, 3), 0, time.Second)
+	must_be.Equal(statusOk, check.Status)
+
+	check = dnsTtlCheck(fakeDnsServer(t, 1, 120), 0, time.Second)
+	must_be.Equal(statusOk, check.Status)
+
+	check = dnsTtlCheck(fakeDnsServer(t, 1), 0, time.Second)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, "stays suspiciously low"))
+
+	ttl, err := dnsQueryTtl(truncatingDnsServer(t), "rcc.example.com", "4", time.Second)
+	must_be.Nil(err)
+	must_be.Equal(uint32(60), ttl)
+
+	must_be.Equal("127.0.0.1:53", dnsServerAddress("127.0.0.1"))
+	must_be.Equal("127.0.0.1:5353", dnsServerAddress("127.0.0.1:5353"))
+	must_be.Equal("", dnsServerAddress(" "))
+}
+
+// fakeDnsReply answers A query with 10.1.2.3 and given TTL
+func fakeDnsReply(query []byte, ttl uint32) []byte {
+	end, ok := skipDnsName(query, 12)
+	if !ok || end+4 > len(query) {
+		return nil
+	}
+	reply := append([]byte{}, query[:end+4]...)
+	binary.BigEndian.PutUint16(reply[2:], 0x8180)
+	binary.BigEndian.PutUint16(reply[10:], 0)
+	if binary.BigEndian.Uint16(query[end:]) == dnsTypeA {
+		binary.BigEndian.PutUint16(reply[6:], 1)
+		reply = append(reply, 0xc0, 12, 0, dnsTypeA, 0, dnsClassIN)
+		reply = binary.BigEndian.AppendUint32(reply, ttl)
+		reply = append(reply, 0, 4, 10, 1, 2, 3)
+	}
+	return reply
+}
+
+// fakeDnsServer answers A queries with 10.1.2.3, using given TTLs in order
+// (last one repeating), or 60 seconds when none are given
+func fakeDnsServer(t *testing.T, ttls ...uint32) string {
+	if len(ttls) == 0 {
+		ttls = []uint32{60}
+	}
+	connection, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			size, client, err := connection.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			reply := fakeDnsReply(buffer[:size], ttls[0])
+			if reply == nil {
+				continue
+			}
+			if len(ttls) > 1 {
+				ttls = ttls[1:]
+			}
+			connection.WriteTo(reply, client)
+		}
+	}()
+	return connection.LocalAddr().String()
+}
+
+// truncatingDnsServer sets truncated flag on all UDP answers, and gives
+// full answers only over TCP on same port
+func truncatingDnsServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	connection, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		t.Skip("cannot listen UDP and TCP on same port:", err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			_, client, err := connection.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			reply := append([]byte{}, buffer[:12]...)
+			binary.BigEndian.PutUint16(reply[2:], 0x8380)
+			connection.WriteTo(reply, client)
+		}
+	}()
+	go func() {
+		for {
+			stream, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			prefix := make([]byte, 2)
+			io.ReadFull(stream, prefix)
+			query := make([]byte, binary.BigEndian.Uint16(prefix))
+			io.ReadFull(stream, query)
+			reply := fakeDnsReply(query, 60)
+			stream.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+			stream.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDnsLookupCanUseSpecificServer(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	resolver, via := dnsResolver("")
+	must_be.Equal(net.DefaultResolver, resolver)
+	must_be.Equal("system resolver", via)
+
+	_, via = dnsResolver("8.8.8.8")
+	must_be.Equal("resolver 8.8.8.8:53", via)
+	_, via = dnsResolver("2001:4860:4860::8888")
+	must_be.Equal("resolver [2001:4860:4860::8888]:53", via)
+
+	server := fakeDnsServer(t)
+	check := dnsLookupCheck("rcc.example.com", server, 5*time.Second)
+	wont_be.Equal(statusFail, check.Status)
+	must_be.True(strings.Contains(check.Message, "10.1.2.3"))
+	must_be.True(strings.Contains(check.Message, server))
+}
+
+func TestHostsFileOverridesAreReported(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	content := "127.0.0.1 localhost\n# 10.0.0.1 api.eu1.robocorp.com\n10.1.2.3\tdownloads.robocorp.com  mirror # local mirror\nnot-an-ip pypi.org\n::1 ip6-localhost\n"
+	static := parseHostsFile(content)
+	must_be.Equal("10.1.2.3", static["downloads.robocorp.com"][0])
+	must_be.Equal(0, len(static["api.eu1.robocorp.com"]))
+	must_be.Equal(0, len(static["pypi.org"]))
+	must_be.Equal("::1", static["ip6-localhost"][0])
+
+	filename := filepath.Join(t.TempDir(), "hosts")
+	must_be.Nil(os.WriteFile(filename, []byte(content), 0o644))
+	checks := hostsFileChecks(filename, []string{"api.eu1.robocorp.com", "Downloads.Robocorp.com"})
+	must_be.Equal(1, len(checks))
+	must_be.Equal(statusWarning, checks[0].Status)
+	must_be.Equal(uint64(common.CategoryNetworkHostsFile), checks[0].Category)
+
+	checks = hostsFileChecks(filename, []string{"api.eu1.robocorp.com"})
+	must_be.Equal(statusOk, checks[0].Status)
+	must_be.Equal(0, len(hostsFileChecks(filename+".missing", []string{"pypi.org"})))
+}
@@ -0,0 +1,102 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/blobs"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/htfs"
+	"github.com/robocorp/rcc/journal"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+type benchmarkPhase struct {
+	name   string
+	marker float64
+}
+
+// buildPhases splits build event timeline into durations of those phases,
+// which actually happened (cached phases have no marker)
+func buildPhases(event *journal.BuildEvent) ([]string, map[string]float64) {
+	phases := []benchmarkPhase{
+		{"resolve", event.Prepared},
+		{"micromamba", event.MicromambaDone},
+		{"pip", event.PipDone},
+		{"postinstall", event.PostInstallDone},
+		{"record", event.RecordDone},
+		{"link", event.RestoreDone},
+	}
+	names := []string{}
+	result := make(map[string]float64)
+	previous := event.Started
+	for _, phase := range phases {
+		if phase.marker <= 0 || phase.marker < previous {
+			continue
+		}
+		names = append(names, phase.name)
+		result[phase.name] = phase.marker - previous
+		previous = phase.marker
+	}
+	return names, result
+}
+
+func benchmarkEnvironment(folder string) (err error) {
+	content, err := blobs.Asset("assets/speedtest.yaml")
+	if err != nil {
+		return err
+	}
+	condafile := filepath.Join(folder, "speedtest.yaml")
+	err = pathlib.WriteFile(condafile, content, 0o666)
+	if err != nil {
+		return err
+	}
+	silent, debug, trace := common.Silent(), common.DebugFlag(), common.TraceFlag()
+	common.DefineVerbosity(true, debug, trace)
+	defer common.DefineVerbosity(silent, debug, trace)
+	common.ForcedRobocorpHome = folder
+	defer func() {
+		common.ForcedRobocorpHome = ""
+	}()
+	_, _, err = htfs.NewEnvironment(condafile, "", true, true, PullCatalog)
+	return err
+}
+
+func environmentBenchmarkCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	folder := filepath.Join(common.RobocorpTemp(), fmt.Sprintf("benchmark_%d", os.Getpid()))
+	defer os.RemoveAll(folder)
+	started := time.Now()
+	err := benchmarkEnvironment(folder)
+	elapsed := time.Since(started)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryEnvironmentBenchmark,
+			Code:     common.CodeEnvBenchmarkFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Environment creation benchmark failed after %s, reason: %v", elapsed.Round(time.Millisecond), err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	names, phases := buildPhases(journal.CurrentBuildEvent())
+	timings := make([]string, 0, len(names))
+	for _, name := range names {
+		details[fmt.Sprintf("benchmark-%s-seconds", name)] = fmt.Sprintf("%.3f", phases[name])
+		timings = append(timings, fmt.Sprintf("%s %.1fs", name, phases[name]))
+	}
+	details["benchmark-total-seconds"] = fmt.Sprintf("%.3f", elapsed.Seconds())
+	return &common.DiagnosticCheck{
+		Type:       "RPA",
+		Category:   common.CategoryEnvironmentBenchmark,
+		Code:       common.CodeEnvBenchmarkOk,
+		Status:     statusOk,
+		Message:    fmt.Sprintf("Throwaway environment was created in %s [%s].", elapsed.Round(time.Millisecond), strings.Join(timings, ", ")),
+		Link:       supportGeneralUrl,
+		DurationMs: elapsed.Milliseconds(),
+	}
+}
@@ -0,0 +1,23 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/journal"
+)
+
+func TestBuildPhasesSkipCachedSteps(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	event := &journal.BuildEvent{Started: 1, Prepared: 3, MicromambaDone: 10, RecordDone: 12, RestoreDone: 12.5}
+	names, phases := buildPhases(event)
+	must_be.Equal([]string{"resolve", "micromamba", "record", "link"}, names)
+	must_be.Equal(2.0, phases["resolve"])
+	must_be.Equal(7.0, phases["micromamba"])
+	must_be.Equal(2.0, phases["record"])
+	must_be.Equal(0.5, phases["link"])
+
+	names, _ = buildPhases(&journal.BuildEvent{Started: 1, RestoreDone: 2})
+	must_be.Equal([]string{"link"}, names)
+}
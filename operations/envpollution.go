@@ -0,0 +1,57 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	pollutingVariables = []string{
+		"CONDA_DEFAULT_ENV",
+		"CONDA_PREFIX",
+		"CONDA_PYTHON_EXE",
+		"PIP_EXTRA_INDEX_URL",
+		"PIP_INDEX_URL",
+		"PIP_PREFIX",
+		"PIP_TARGET",
+		"PIP_USER",
+		"PYTHONHOME",
+		"PYTHONPATH",
+		"PYTHONSTARTUP",
+		"PYTHONUSERBASE",
+		"VIRTUAL_ENV",
+	}
+)
+
+func environmentPollutionCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	found := []string{}
+	for _, key := range pollutingVariables {
+		value, ok := os.LookupEnv(key)
+		if ok {
+			found = append(found, fmt.Sprintf("%s=%q", key, value))
+		}
+	}
+	if len(found) > 0 {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryEnvPollution,
+			Code:     common.CodeEnvPolluted,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Python/conda variables set in environment may leak into holotree environments and break them: %s", strings.Join(found, ", ")),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryEnvPollution,
+		Code:     common.CodeEnvPollutionOk,
+		Status:   statusOk,
+		Message:  "No conflicting Python/conda variables found in environment.",
+		Link:     supportGeneralUrl,
+	}
+}
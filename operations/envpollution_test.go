@@ -0,0 +1,28 @@
+package operations
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestEnvironmentPollutionListsFoundVariables(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	for _, key := range pollutingVariables {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	check := environmentPollutionCheck()
+	must_be.Equal(statusOk, check.Status)
+
+	t.Setenv("PYTHONHOME", "/opt/python")
+	t.Setenv("PIP_INDEX_URL", "https://mirror.example.com/simple")
+	check = environmentPollutionCheck()
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, `PYTHONHOME="/opt/python"`))
+	must_be.True(strings.Contains(check.Message, "PIP_INDEX_URL"))
+	wont_be.True(strings.Contains(check.Message, "CONDA_PREFIX"))
+}
@@ -0,0 +1,40 @@
+package operations
+
+import (
+	"net"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+)
+
+// primaryHardwareAddress picks MAC of lowest index non-loopback interface,
+// skipping locally administered addresses (virtual, container, and random
+// MACs), so that result stays same over reboots and rcc upgrades
+func primaryHardwareAddress(interfaces []net.Interface) string {
+	for _, candidate := range interfaces {
+		address := candidate.HardwareAddr
+		if candidate.Flags&net.FlagLoopback != 0 || len(address) < 6 {
+			continue
+		}
+		if address[0]&0x02 != 0 {
+			continue
+		}
+		return address.String()
+	}
+	return ""
+}
+
+func fingerprintOf(machineId, hardwareAddress string) string {
+	machineId = strings.ToLower(strings.TrimSpace(machineId))
+	if len(machineId) == 0 && len(hardwareAddress) == 0 {
+		return ""
+	}
+	return common.Digest("rcc-machine-fingerprint|" + machineId + "|" + hardwareAddress)[:32]
+}
+
+// machineFingerprint is hashed (non-PII) identity of this machine, which
+// unlike installationId survives reinstalls of rcc
+func machineFingerprint() string {
+	interfaces, _ := net.Interfaces()
+	return fingerprintOf(hostMachineId(), primaryHardwareAddress(interfaces))
+}
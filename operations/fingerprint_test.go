@@ -0,0 +1,31 @@
+package operations
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestMachineFingerprintIsStableAndHashed(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	physical, _ := net.ParseMAC("00:1a:2b:3c:4d:5e")
+	virtual, _ := net.ParseMAC("02:42:ac:11:00:02")
+	interfaces := []net.Interface{
+		{Index: 1, Name: "lo", Flags: net.FlagLoopback},
+		{Index: 2, Name: "docker0", HardwareAddr: virtual},
+		{Index: 3, Name: "eth0", HardwareAddr: physical},
+	}
+	must_be.Equal("00:1a:2b:3c:4d:5e", primaryHardwareAddress(interfaces))
+	must_be.Equal("", primaryHardwareAddress(interfaces[:2]))
+
+	must_be.Equal("", fingerprintOf(" ", ""))
+	first := fingerprintOf("0123abcd\n", "00:1a:2b:3c:4d:5e")
+	must_be.Equal(32, len(first))
+	must_be.Equal(first, fingerprintOf("0123ABCD", "00:1a:2b:3c:4d:5e"))
+	wont_be.Equal(first, fingerprintOf("0123abcd", ""))
+	wont_be.True(strings.Contains(first, "0123abcd"))
+	must_be.Equal(machineFingerprint(), machineFingerprint())
+}
@@ -0,0 +1,145 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	benchmarkChunkSize    = 1024 * 1024
+	benchmarkChunkCount   = 16
+	benchmarkFileCount    = 500
+	benchmarkWriteMinimum = 50.0
+	benchmarkReadMinimum  = 100.0
+	benchmarkFileMinimum  = 500.0
+)
+
+type ioBenchmark struct {
+	WriteSpeed float64
+	ReadSpeed  float64
+	FileRate   float64
+}
+
+func perSecond(amount float64, elapsed common.Duration) float64 {
+	seconds := time.Duration(elapsed).Seconds()
+	if seconds <= 0 {
+		seconds = 0.001
+	}
+	return amount / seconds
+}
+
+func megabytesPerSecond(size int, elapsed common.Duration) float64 {
+	return perSecond(float64(size)/(1024.0*1024.0), elapsed)
+}
+
+func benchmarkWrite(filename string) (speed float64, err error) {
+	defer fail.Around(&err)
+
+	chunk := make([]byte, benchmarkChunkSize)
+	for at := range chunk {
+		chunk[at] = byte(at % 251)
+	}
+	stopwatch := common.Stopwatch("write")
+	sink, err := os.Create(filename)
+	fail.On(err != nil, "Could not create %q, reason: %v", filename, err)
+	defer sink.Close()
+	for round := 0; round < benchmarkChunkCount; round++ {
+		_, err = sink.Write(chunk)
+		fail.On(err != nil, "Could not write %q, reason: %v", filename, err)
+	}
+	err = sink.Sync()
+	fail.On(err != nil, "Could not sync %q, reason: %v", filename, err)
+	return megabytesPerSecond(benchmarkChunkSize*benchmarkChunkCount, stopwatch.Elapsed()), nil
+}
+
+func benchmarkRead(filename string) (speed float64, err error) {
+	defer fail.Around(&err)
+
+	stopwatch := common.Stopwatch("read")
+	source, err := os.Open(filename)
+	fail.On(err != nil, "Could not open %q, reason: %v", filename, err)
+	defer source.Close()
+	digester := common.NewDigester(false)
+	size, err := io.Copy(digester, source)
+	fail.On(err != nil, "Could not read %q, reason: %v", filename, err)
+	return megabytesPerSecond(int(size), stopwatch.Elapsed()), nil
+}
+
+func benchmarkSmallFiles(directory string) (rate float64, err error) {
+	defer fail.Around(&err)
+
+	content := []byte(strings.Repeat("small file content\n", 50))
+	stopwatch := common.Stopwatch("small files")
+	for at := 0; at < benchmarkFileCount; at++ {
+		filename := filepath.Join(directory, fmt.Sprintf("small_%04d.txt", at))
+		err = os.WriteFile(filename, content, 0o644)
+		fail.On(err != nil, "Could not create %q, reason: %v", filename, err)
+		err = os.Remove(filename)
+		fail.On(err != nil, "Could not remove %q, reason: %v", filename, err)
+	}
+	return perSecond(benchmarkFileCount, stopwatch.Elapsed()), nil
+}
+
+func runIoBenchmark(location string) (result *ioBenchmark, err error) {
+	defer fail.Around(&err)
+
+	directory, err := os.MkdirTemp(location, "iobenchmark")
+	fail.On(err != nil, "Could not create benchmark directory under %q, reason: %v", location, err)
+	defer os.RemoveAll(directory)
+
+	result = &ioBenchmark{}
+	filename := filepath.Join(directory, "sequential.bin")
+	result.WriteSpeed, err = benchmarkWrite(filename)
+	fail.Fast(err)
+	result.ReadSpeed, err = benchmarkRead(filename)
+	fail.Fast(err)
+	result.FileRate, err = benchmarkSmallFiles(directory)
+	fail.Fast(err)
+	return result, nil
+}
+
+func ioBenchmarkCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	location := common.HololibLocation()
+	measured, err := runIoBenchmark(location)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHolotreeBenchmark,
+			Code:     common.CodeIoBenchmarkFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("IO benchmark on %q failed, reason: %v", location, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	details["io-benchmark-write-mbps"] = fmt.Sprintf("%.1f", measured.WriteSpeed)
+	details["io-benchmark-read-hash-mbps"] = fmt.Sprintf("%.1f", measured.ReadSpeed)
+	details["io-benchmark-small-files-per-second"] = fmt.Sprintf("%.1f", measured.FileRate)
+	summary := fmt.Sprintf("write %.1f MB/s, read+hash %.1f MB/s, small file create+delete %.1f files/s", measured.WriteSpeed, measured.ReadSpeed, measured.FileRate)
+	if measured.WriteSpeed < benchmarkWriteMinimum || measured.ReadSpeed < benchmarkReadMinimum || measured.FileRate < benchmarkFileMinimum {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryHolotreeBenchmark,
+			Code:     common.CodeIoBenchmarkSlow,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Holotree storage %q is slow: %s. Expect slow environment operations.", location, summary),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryHolotreeBenchmark,
+		Code:     common.CodeIoBenchmarkOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Holotree storage %q performance: %s.", location, summary),
+		Link:     supportGeneralUrl,
+	}
+}
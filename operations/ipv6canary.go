@@ -0,0 +1,81 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+// ipv6Dialer dials only over IPv6, whatever family caller asks for, so that
+// IPv6 path gets checked even when normal dialer would pick IPv4
+func ipv6Dialer(dialer *net.Dialer) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, strings.TrimRight(network, "46")+"6", address)
+	}
+}
+
+func ipv6Required() bool {
+	return settings.Global.IpFamily() == "ipv6"
+}
+
+func ipv6Possible(*DiagnosticsOptions) bool {
+	return settings.Global.IpFamily() != "ipv4"
+}
+
+// canaryIpv6Check downloads canary over IPv6 connections only, to validate
+// IPv6-only reachability end to end; problems are just warnings, unless
+// IPv6 is also forced as network family
+func canaryIpv6Check(link, expected string, transport *http.Transport, timeout time.Duration, required bool) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.CanaryHelpLink()
+	problem := statusWarning
+	if required {
+		problem = statusFail
+	}
+	forced := transport.Clone()
+	forced.DialContext = ipv6Dialer(&net.Dialer{Timeout: timeout})
+	client := &http.Client{Transport: forced, Timeout: timeout}
+	started := time.Now()
+	response, err := client.Get(link)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCanaryIPv6,
+			Code:       common.CodeCanaryIPv6Failed,
+			Status:     problem,
+			Message:    fmt.Sprintf("Canary download from %s over IPv6 failed: %v", link, err),
+			Link:       supportNetworkUrl,
+			DurationMs: time.Since(started).Milliseconds(),
+		}
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	elapsed := time.Since(started).Milliseconds()
+	_, matches := canaryWrapper(body, expected)
+	if err != nil || response.StatusCode != 200 || !matches {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkCanaryIPv6,
+			Code:       common.CodeCanaryIPv6Status,
+			Status:     problem,
+			Message:    fmt.Sprintf("Canary download from %s over IPv6 failed at HTTP level: status %d, body %q", link, response.StatusCode, body),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:       "network",
+		Category:   common.CategoryNetworkCanaryIPv6,
+		Code:       common.CodeCanaryIPv6Ok,
+		Status:     statusOk,
+		Message:    fmt.Sprintf("Canary download over IPv6 successful [GET request]: %s", link),
+		Link:       supportNetworkUrl,
+		DurationMs: elapsed,
+	}
+}
@@ -0,0 +1,45 @@
+package operations
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCanaryIpv6CheckUsesOnlyIpv6(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(writer, "canary")
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	warning := canaryIpv6Check(server.URL, "canary", &http.Transport{}, time.Second, false)
+	must_be.Equal(statusWarning, warning.Status)
+	must_be.Equal(uint64(common.CategoryNetworkCanaryIPv6), warning.Category)
+	must_be.True(strings.Contains(warning.Message, "over IPv6 failed"))
+
+	failure := canaryIpv6Check(server.URL, "canary", &http.Transport{}, time.Second, true)
+	must_be.Equal(statusFail, failure.Status)
+
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		return
+	}
+	loopback := httptest.NewUnstartedServer(handler)
+	loopback.Listener.Close()
+	loopback.Listener = listener
+	loopback.Start()
+	defer loopback.Close()
+
+	success := canaryIpv6Check(loopback.URL, "canary", &http.Transport{}, time.Second, true)
+	must_be.Equal(statusOk, success.Status)
+}
@@ -0,0 +1,64 @@
+package operations
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+func loopbackRoundtrip() (address string, err error) {
+	defer fail.Around(&err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	fail.On(err != nil, "Could not bind localhost port, reason: %v", err)
+	defer listener.Close()
+	address = listener.Addr().String()
+
+	accepted := make(chan error, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err == nil {
+			connection.Close()
+		}
+		accepted <- err
+	}()
+
+	connection, err := net.DialTimeout("tcp", address, 3*time.Second)
+	fail.On(err != nil, "Could not connect to %s, reason: %v", address, err)
+	connection.Close()
+
+	select {
+	case err = <-accepted:
+		fail.On(err != nil, "Could not accept connection on %s, reason: %v", address, err)
+	case <-time.After(3 * time.Second):
+		fail.On(true, "Timeout while accepting connection on %s", address)
+	}
+	return address, nil
+}
+
+func localListenerCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	address, err := loopbackRoundtrip()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryLocalListener,
+			Code:     common.CodeListenerBlocked,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Local listener or loopback connection is blocked: %v. Features needing localhost callbacks will fail.", err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryLocalListener,
+		Code:     common.CodeListenerOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Local listener on %s accepted loopback connection.", address),
+		Link:     supportGeneralUrl,
+	}
+}
@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/robocorp/rcc/blobs"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+func micromambaIntegrity(binary, expected string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	if !pathlib.IsFile(binary) {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaIntegrity,
+			Code:     common.CodeMicromambaNotExtracted,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Micromamba %q is not extracted yet. rcc will extract it when needed.", binary),
+			Link:     supportGeneralUrl,
+		}
+	}
+	actual, err := pathlib.Sha256(binary)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaIntegrity,
+			Code:     common.CodeMicromambaUnreadable,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Could not read micromamba %q for integrity check, reason: %v", binary, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	if actual != expected {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaIntegrity,
+			Code:     common.CodeMicromambaCorrupted,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Micromamba %q is corrupted or modified (sha256 %s, expected %s). Remove it, and rcc will extract it again.", binary, actual, expected),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryMicromambaIntegrity,
+		Code:     common.CodeMicromambaIntact,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Micromamba %q matches binary shipped with rcc %s.", binary, common.Version),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func micromambaIntegrityCheck() *common.DiagnosticCheck {
+	expected, err := blobs.MicromambaDigest()
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryMicromambaIntegrity,
+			Code:     common.CodeMicromambaNoDigest,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not compute digest of micromamba embedded in rcc, reason: %v", err),
+			Link:     settings.Global.DocsLink("troubleshooting"),
+		}
+	}
+	return micromambaIntegrity(conda.BinMicromamba(), expected)
+}
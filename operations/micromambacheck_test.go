@@ -0,0 +1,26 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+	"github.com/robocorp/rcc/pathlib"
+)
+
+func TestMicromambaIntegrityComparesDigests(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	binary := filepath.Join(t.TempDir(), "micromamba")
+	must_be.Equal(statusOk, micromambaIntegrity(binary, "whatever").Status)
+
+	must_be.Nil(os.WriteFile(binary, []byte("dummy\n"), 0o755))
+	expected, err := pathlib.Sha256(binary)
+	must_be.Nil(err)
+	must_be.Equal(statusOk, micromambaIntegrity(binary, expected).Status)
+	check := micromambaIntegrity(binary, strings.Repeat("0", 64))
+	must_be.Equal(statusFail, check.Status)
+	must_be.True(strings.Contains(check.Message, expected))
+}
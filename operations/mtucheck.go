@@ -0,0 +1,128 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	errTransferStalled = errors.New("transfer stalled")
+)
+
+// largeTransfer reads up to size bytes from link, and gives up when no bytes
+// arrive within stall duration; received tells how far transfer got
+func largeTransfer(link string, size int64, stall time.Duration) (received int64, err error) {
+	defer fail.Around(&err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchdog := time.AfterFunc(stall, cancel)
+	defer watchdog.Stop()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	fail.On(err != nil, "Could not create request, reason: %v", err)
+	request.Header.Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+	request.Header.Set("User-Agent", common.UserAgent())
+	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	response, err := client.Do(request)
+	fail.On(err != nil && ctx.Err() != nil, "%v after %s", errTransferStalled, stall)
+	fail.On(err != nil, "%v", err)
+	defer response.Body.Close()
+	fail.On(response.StatusCode != http.StatusPartialContent && response.StatusCode != http.StatusOK, "Unexpected status %q", response.Status)
+
+	expected := size
+	if response.ContentLength > 0 && response.ContentLength < size {
+		expected = response.ContentLength
+	}
+	buffer := make([]byte, 32*1024)
+	for received < expected {
+		watchdog.Reset(stall)
+		count, err := response.Body.Read(buffer)
+		received += int64(count)
+		if err == io.EOF && response.ContentLength < 0 {
+			expected = received
+		}
+		if err == io.EOF {
+			break
+		}
+		fail.On(err != nil && ctx.Err() != nil, "%v after %s", errTransferStalled, stall)
+		fail.On(err != nil, "%v", err)
+	}
+	fail.On(received < expected, "Transfer truncated, expected %d bytes", expected)
+	if received > expected {
+		received = expected
+	}
+	return received, nil
+}
+
+// throughputMbps is effective transfer speed in megabits per second
+func throughputMbps(received int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(received) * 8 / elapsed.Seconds() / 1e6
+}
+
+func throughputCheck(details map[string]string, link string, received int64, elapsed time.Duration, floor float64) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	speed := throughputMbps(received, elapsed)
+	details["download-throughput-mbps"] = fmt.Sprintf("%.2f", speed)
+	if speed < floor {
+		return &common.DiagnosticCheck{
+			Type:       "network",
+			Category:   common.CategoryNetworkThroughput,
+			Code:       common.CodeThroughputLow,
+			Status:     statusWarning,
+			Message:    fmt.Sprintf("Download throughput from %s was %.2f Mbps (%d bytes in %s), which is below %.2f Mbps. Environment builds will be slow.", link, speed, received, elapsed.Round(time.Millisecond), floor),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed.Milliseconds(),
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:       "network",
+		Category:   common.CategoryNetworkThroughput,
+		Code:       common.CodeThroughputOk,
+		Status:     statusOk,
+		Message:    fmt.Sprintf("Download throughput from %s was %.2f Mbps (%d bytes in %s).", link, speed, received, elapsed.Round(time.Millisecond)),
+		Link:       supportNetworkUrl,
+		DurationMs: elapsed.Milliseconds(),
+	}
+}
+
+func mtuPathChecks(details map[string]string) []*common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	link := conda.MicromambaLink()
+	size := settings.Global.DiagnosticsPayloadSize()
+	started := time.Now()
+	received, err := largeTransfer(link, size, settings.Global.DiagnosticsStallTimeout())
+	elapsed := time.Since(started)
+	if err != nil {
+		return []*common.DiagnosticCheck{{
+			Type:       "network",
+			Category:   common.CategoryNetworkLargePayload,
+			Code:       common.CodeLargePayloadStopped,
+			Status:     statusFail,
+			Message:    fmt.Sprintf("Large download from %s stopped at byte offset %d of %d: %v. Maybe VPN or firewall drops large packets (MTU).", link, received, size, err),
+			Link:       supportNetworkUrl,
+			DurationMs: elapsed.Milliseconds(),
+		}}
+	}
+	return []*common.DiagnosticCheck{{
+		Type:       "network",
+		Category:   common.CategoryNetworkLargePayload,
+		Code:       common.CodeLargePayloadOk,
+		Status:     statusOk,
+		Message:    fmt.Sprintf("Large download from %s completed with %d bytes.", link, received),
+		Link:       supportNetworkUrl,
+		DurationMs: elapsed.Milliseconds(),
+	}, throughputCheck(details, link, received, elapsed, settings.Global.DiagnosticsMinimumMbps())}
+}
@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestLargeTransferDetectsTruncationAndStalls(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	payload := make([]byte, 256*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/full":
+			w.Write(payload)
+		case "/truncated":
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(payload)))
+			w.Write(payload[:1000])
+		case "/stalled":
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(payload)))
+			w.Write(payload[:2000])
+			w.(http.Flusher).Flush()
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	received, err := largeTransfer(server.URL+"/full", 100*1024, time.Second)
+	must_be.Nil(err)
+	must_be.Equal(int64(100*1024), received)
+
+	received, err = largeTransfer(server.URL+"/full", 1024*1024, time.Second)
+	must_be.Nil(err)
+	must_be.Equal(int64(len(payload)), received)
+
+	received, err = largeTransfer(server.URL+"/truncated", 100*1024, time.Second)
+	wont_be.Nil(err)
+	must_be.Equal(int64(1000), received)
+
+	received, err = largeTransfer(server.URL+"/stalled", 100*1024, 100*time.Millisecond)
+	wont_be.Nil(err)
+	must_be.True(strings.Contains(err.Error(), "stalled"))
+	must_be.Equal(int64(2000), received)
+}
+
+func TestThroughputIsMeasuredInMegabits(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(8.0, throughputMbps(1000000, time.Second))
+	must_be.Equal(0.0, throughputMbps(1000000, 0))
+
+	details := make(map[string]string)
+	check := throughputCheck(details, "https://example.com/payload", 4*1000*1000, 2*time.Second, 2.0)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal(uint64(common.CategoryNetworkThroughput), check.Category)
+	must_be.Equal("16.00", details["download-throughput-mbps"])
+
+	check = throughputCheck(details, "https://example.com/payload", 100*1000, 2*time.Second, 2.0)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.Equal("0.40", details["download-throughput-mbps"])
+}
@@ -72,7 +72,7 @@ func networkDiagnostics(config *Configuration, target *common.DiagnosticStatus)
 	hostnames := config.Network.Hostnames()
 	dnsStopwatch := common.Stopwatch("DNS lookup time for %d hostnames was about", len(hostnames))
 	for _, host := range hostnames {
-		target.Checks = append(target.Checks, dnsLookupCheck(host))
+		target.Checks = append(target.Checks, dnsLookupCheck(host, "", settings.Global.DiagnosticsTimeout()))
 	}
 	target.Details["dns-lookup-time"] = dnsStopwatch.Text()
 	tlsRoots := make(map[string]bool)
@@ -88,21 +88,35 @@ func networkDiagnostics(config *Configuration, target *common.DiagnosticStatus)
 	} else {
 		target.Details["tls-proxy-firewall"] = "undetectable"
 	}
+	target.Checks = append(target.Checks, just(tlsRootStoreCheck(target.Details), tlsIssuersCheck(tlsRoots))...)
 	headStopwatch := common.Stopwatch("HEAD request time for %d requests was about", len(config.Network.Head))
 	for _, entry := range config.Network.Head {
-		target.Checks = append(target.Checks, webDiagnostics("HEAD", common.CategoryNetworkHEAD, headRequest, entry, supportUrl)...)
+		target.Checks = append(target.Checks, webDiagnostics("HEAD", common.CategoryNetworkHEAD, headCodes, headRequest, entry, supportUrl)...)
 	}
 	target.Details["head-time"] = headStopwatch.Text()
 	getStopwatch := common.Stopwatch("GET request time for %d requests was about", len(config.Network.Get))
 	for _, entry := range config.Network.Get {
-		target.Checks = append(target.Checks, webDiagnostics("GET", common.CategoryNetworkCanary, getRequest, entry, supportUrl)...)
+		target.Checks = append(target.Checks, webDiagnostics("GET", common.CategoryNetworkCanary, getCodes, getRequest, entry, supportUrl)...)
 	}
 	target.Details["get-time"] = getStopwatch.Text()
 	target.Details["diagnostics-time"] = diagnosticsStopwatch.Text()
 	return target.Checks
 }
 
-func webDiagnostics(label string, category uint64, tool webtool, item *WebConfig, supportUrl string) []*common.DiagnosticCheck {
+// webCodes are check codes of configured web diagnostics outcomes
+type webCodes struct {
+	ok          uint64
+	status      uint64
+	failure     uint64
+	fingerprint uint64
+}
+
+var (
+	headCodes = &webCodes{common.CodeHeadOk, common.CodeHeadStatus, common.CodeHeadError, common.CodeHeadFingerprint}
+	getCodes  = &webCodes{common.CodeCanaryOk, common.CodeCanaryStatus, common.CodeCanaryError, common.CodeCanaryFingerprint}
+)
+
+func webDiagnostics(label string, category uint64, codes *webCodes, tool webtool, item *WebConfig, supportUrl string) []*common.DiagnosticCheck {
 	result := make([]*common.DiagnosticCheck, 0, 2)
 	code, fingerprint, err := tool(item.URL)
 	valid := set.Set(item.Codes)
@@ -111,6 +125,7 @@ func webDiagnostics(label string, category uint64, tool webtool, item *WebConfig
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: category,
+			Code:     codes.ok,
 			Status:   statusOk,
 			Message:  fmt.Sprintf("%s %q successful with status %d.", label, item.URL, code),
 			Link:     supportUrl,
@@ -119,6 +134,7 @@ func webDiagnostics(label string, category uint64, tool webtool, item *WebConfig
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: category,
+			Code:     codes.status,
 			Status:   statusFail,
 			Message:  fmt.Sprintf("%s %q failed with status %d.", label, item.URL, code),
 			Link:     supportUrl,
@@ -128,6 +144,7 @@ func webDiagnostics(label string, category uint64, tool webtool, item *WebConfig
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: category,
+			Code:     codes.failure,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%s %q resulted error: %v.", label, item.URL, err),
 			Link:     supportUrl,
@@ -137,6 +154,7 @@ func webDiagnostics(label string, category uint64, tool webtool, item *WebConfig
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: category,
+			Code:     codes.fingerprint,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("%s %q fingerprint mismatch: expected %q, but got %q instead.", label, item.URL, item.Fingerprint, fingerprint),
 			Link:     supportUrl,
@@ -0,0 +1,207 @@
+package operations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+// minimal RFC 6960 structures, since golang.org/x/crypto is not among rcc
+// dependencies; signature is verified against issuer (or responder signed
+// by issuer), and unverified responses are never reported as revoked
+
+const (
+	ocspGood    = `good`
+	ocspRevoked = `revoked`
+	ocspUnknown = `unknown`
+)
+
+var (
+	ocspBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	ocspSignatures    = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+		"1.3.101.112":           x509.PureEd25519,
+	}
+)
+
+type (
+	ocspResponse struct {
+		Status   asn1.Enumerated
+		Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+	}
+
+	ocspResponseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+
+	ocspBasic struct {
+		TBSResponseData    ocspResponseData
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+		Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+	}
+
+	ocspResponseData struct {
+		Raw            asn1.RawContent
+		Version        int `asn1:"optional,default:0,explicit,tag:0"`
+		RawResponderID asn1.RawValue
+		ProducedAt     time.Time `asn1:"generalized"`
+		Responses      []ocspSingleResponse
+	}
+
+	ocspSingleResponse struct {
+		CertID           ocspCertID
+		Good             asn1.Flag        `asn1:"tag:0,optional"`
+		Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+		Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+		ThisUpdate       time.Time        `asn1:"generalized"`
+		NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+		SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+	}
+
+	ocspCertID struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		NameHash      []byte
+		IssuerKeyHash []byte
+		SerialNumber  *big.Int
+	}
+
+	ocspRevokedInfo struct {
+		RevocationTime time.Time       `asn1:"generalized"`
+		Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+	}
+)
+
+func (it *ocspSingleResponse) status() string {
+	switch {
+	case bool(it.Good):
+		return ocspGood
+	case bool(it.Unknown):
+		return ocspUnknown
+	case !it.Revoked.RevocationTime.IsZero():
+		return ocspRevoked
+	}
+	return ocspUnknown
+}
+
+// ocspSigner returns certificate, which signed basic response: issuer
+// itself, or delegated responder certificate issued by it for OCSP signing
+func ocspSigner(basic *ocspBasic, issuer *x509.Certificate) (signer *x509.Certificate, err error) {
+	defer fail.Around(&err)
+
+	fail.On(issuer == nil, "Issuer certificate is not known, so OCSP signature cannot be verified.")
+	algorithm, ok := ocspSignatures[basic.SignatureAlgorithm.Algorithm.String()]
+	fail.On(!ok, "Unsupported OCSP signature algorithm %v.", basic.SignatureAlgorithm.Algorithm)
+	signed, signature := basic.TBSResponseData.Raw, basic.Signature.RightAlign()
+	if issuer.CheckSignature(algorithm, signed, signature) == nil {
+		return issuer, nil
+	}
+	for _, raw := range basic.Certificates {
+		responder, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil || responder.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		delegated := false
+		for _, usage := range responder.ExtKeyUsage {
+			delegated = delegated || usage == x509.ExtKeyUsageOCSPSigning
+		}
+		if delegated && responder.CheckSignature(algorithm, signed, signature) == nil {
+			return responder, nil
+		}
+	}
+	return nil, fmt.Errorf("OCSP response signature does not verify against issuer %q.", issuer.Subject)
+}
+
+func parseStapledOcsp(raw []byte, leaf, issuer *x509.Certificate) (single *ocspSingleResponse, err error) {
+	defer fail.Around(&err)
+
+	response := ocspResponse{}
+	rest, err := asn1.Unmarshal(raw, &response)
+	fail.On(err != nil, "Could not parse OCSP response, reason: %v", err)
+	fail.On(len(rest) > 0, "Trailing data after OCSP response.")
+	fail.On(response.Status != 0, "OCSP responder status was %d, not successful.", response.Status)
+	fail.On(!response.Response.ResponseType.Equal(ocspBasicResponse), "Unsupported OCSP response type %v.", response.Response.ResponseType)
+
+	basic := ocspBasic{}
+	_, err = asn1.Unmarshal(response.Response.Response, &basic)
+	fail.On(err != nil, "Could not parse basic OCSP response, reason: %v", err)
+	_, err = ocspSigner(&basic, issuer)
+	fail.On(err != nil, "%v", err)
+	for at, candidate := range basic.TBSResponseData.Responses {
+		if candidate.CertID.SerialNumber != nil && candidate.CertID.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			return &basic.TBSResponseData.Responses[at], nil
+		}
+	}
+	return nil, fmt.Errorf("OCSP response has no status for certificate serial %v.", leaf.SerialNumber)
+}
+
+// ocspIssuer is issuer of leaf from verified chain, or from peer certificates
+func ocspIssuer(state *tls.ConnectionState) *x509.Certificate {
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 1 {
+		return state.VerifiedChains[0][1]
+	}
+	if len(state.PeerCertificates) > 1 {
+		return state.PeerCertificates[1]
+	}
+	return nil
+}
+
+func tlsOcspCheck(server string, stapled []byte, leaf, issuer *x509.Certificate) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	if len(stapled) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkOCSP,
+			Code:     common.CodeOcspNotStapled,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Server %q does not staple OCSP response.", server),
+			Link:     supportNetworkUrl,
+		}
+	}
+	single, err := parseStapledOcsp(stapled, leaf, issuer)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkOCSP,
+			Code:     common.CodeOcspUnverified,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Server %q stapled OCSP response, but it was not understood or verified, so status is unknown: %v", server, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	next := ""
+	if !single.NextUpdate.IsZero() {
+		next = fmt.Sprintf(" [next update %s]", single.NextUpdate.UTC().Format(time.RFC3339))
+	}
+	status, code := statusOk, uint64(common.CodeOcspGood)
+	switch single.status() {
+	case ocspRevoked:
+		status, code = statusFail, common.CodeOcspRevoked
+	case ocspUnknown:
+		status, code = statusWarning, common.CodeOcspUnknown
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkOCSP,
+		Code:     code,
+		Status:   status,
+		Message:  fmt.Sprintf("Server %q stapled OCSP status is %s.%s", server, single.status(), next),
+		Link:     supportNetworkUrl,
+	}
+}
@@ -0,0 +1,381 @@
+package operations
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/set"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	internalSuffixes = []string{".local", ".localdomain", ".internal", ".intranet", ".corp", ".lan", ".home.arpa"}
+	proxyVariables   = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"}
+	noProxyVariables = []string{"NO_PROXY", "no_proxy"}
+)
+
+func looksInternalHost(host string) bool {
+	ip := net.ParseIP(host)
+	if ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+	}
+	lowhost := strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.Contains(lowhost, ".") {
+		return true
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(lowhost, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func noProxyEntries() []string {
+	values := []string{settings.Global.NoProxy()}
+	for _, key := range noProxyVariables {
+		values = append(values, os.Getenv(key))
+	}
+	result := []string{}
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			if len(entry) > 0 {
+				result = append(result, entry)
+			}
+		}
+	}
+	return set.Set(result)
+}
+
+// noProxyProblems lists NO_PROXY entries, which do not work as intended in
+// rcc, or in tools (pip, micromamba) which have their own matching rules
+func noProxyProblems(entries []string) []string {
+	result := []string{}
+	for _, entry := range entries {
+		_, _, notCidr := net.ParseCIDR(entry)
+		switch {
+		case strings.Contains(entry, "://"), strings.Contains(entry, "/") && notCidr != nil:
+			result = append(result, fmt.Sprintf("%q should be just host or domain, without scheme or path", entry))
+		case notCidr == nil:
+			result = append(result, fmt.Sprintf("%q is CIDR range, which only matches IP addresses (not names) and is ignored by some tools", entry))
+		case entry != "*" && strings.Contains(entry, "*"):
+			result = append(result, fmt.Sprintf("%q uses wildcard, which many tools ignore; use %q instead", entry, strings.TrimPrefix(entry, "*")))
+		}
+	}
+	return result
+}
+
+// noProxyMatch returns NO_PROXY entry, which makes host on port bypass the
+// proxy, following Go rules: plain domain entries match domain itself and
+// its subdomains, ".domain" and "*.domain" entries match only subdomains,
+// and entries with port match only that port
+func noProxyMatch(host, port string, entries []string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, entry := range entries {
+		if entry == "*" {
+			return entry
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return entry
+			}
+			continue
+		}
+		name, wanted := strings.ToLower(entry), ""
+		if hostname, entryPort, err := net.SplitHostPort(name); err == nil {
+			name, wanted = strings.Trim(hostname, "[]"), entryPort
+		}
+		if len(name) == 0 || (len(wanted) > 0 && wanted != port) {
+			continue
+		}
+		if exact := net.ParseIP(name); exact != nil {
+			if exact.Equal(ip) {
+				return entry
+			}
+			continue
+		}
+		if ip != nil {
+			continue
+		}
+		if strings.HasPrefix(name, "*.") {
+			name = name[1:]
+		}
+		if strings.HasPrefix(name, ".") {
+			if strings.HasSuffix(host, name) {
+				return entry
+			}
+			continue
+		}
+		if host == name || strings.HasSuffix(host, "."+name) {
+			return entry
+		}
+	}
+	return ""
+}
+
+func proxyFor(transport *http.Transport, host string) (*url.URL, error) {
+	if transport.Proxy == nil {
+		return nil, nil
+	}
+	request, err := http.NewRequest("GET", fmt.Sprintf("https://%s/", host), nil)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Proxy(request)
+}
+
+func proxyRoutingChecks(hostnames []string) []*common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	transport := settings.Global.ConfiguredHttpTransport()
+	entries := noProxyEntries()
+	result := make([]*common.DiagnosticCheck, 0, len(hostnames)+1)
+	problems := noProxyProblems(entries)
+	if len(problems) > 0 {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyRouting,
+			Code:     common.CodeNoProxyProblems,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("NO_PROXY configuration has problems: %s.", strings.Join(problems, "; ")),
+			Link:     supportNetworkUrl,
+		})
+	}
+	for _, host := range hostnames {
+		matched := noProxyMatch(host, "443", entries)
+		proxy, err := proxyFor(transport, host)
+		if err != nil {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyRouting,
+				Code:     common.CodeProxyRoutingUnknown,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("Could not resolve proxy routing for %q, reason: %v", host, err),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		if proxy == nil && len(matched) > 0 {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyRouting,
+				Code:     common.CodeProxyBypassed,
+				Status:   statusOk,
+				Message:  fmt.Sprintf("%q bypasses proxy [NO_PROXY entry %q].", host, matched),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		if proxy == nil {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyRouting,
+				Code:     common.CodeProxyDirect,
+				Status:   statusOk,
+				Message:  fmt.Sprintf("%q is connected directly [no proxy].", host),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		if len(matched) > 0 {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyRouting,
+				Code:     common.CodeProxyBypassIgnored,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("%q matches NO_PROXY entry %q, but rcc still routes it via proxy %s (settings proxy applies to all hosts). Tools honoring NO_PROXY will connect directly.", host, matched, proxy.Redacted()),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		if looksInternalHost(host) {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyRouting,
+				Code:     common.CodeProxyInternalHost,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("%q looks like internal host, but is routed via proxy %s. Check your NO_PROXY configuration.", host, proxy.Redacted()),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyRouting,
+			Code:     common.CodeProxyRouted,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("%q is routed via proxy %s.", host, proxy.Redacted()),
+			Link:     supportNetworkUrl,
+		})
+	}
+	return result
+}
+
+// redactedProxy hides password part from proxy values, also from those
+// which are not valid URLs (like "user:secret@proxy:8080")
+func redactedProxy(value string) string {
+	at := strings.LastIndex(value, "@")
+	if at < 0 {
+		return value
+	}
+	start := strings.Index(value, "://") + 1
+	if start > 0 {
+		start += 2
+	}
+	colon := strings.Index(value[start:at], ":")
+	if colon < 0 {
+		return value
+	}
+	return value[:start+colon+1] + "***" + value[at:]
+}
+
+func proxyEnvironmentDetails(details map[string]string) {
+	for _, key := range append(append([]string{}, proxyVariables...), noProxyVariables...) {
+		value, ok := os.LookupEnv(key)
+		if ok {
+			details[key] = redactedProxy(value)
+		}
+	}
+}
+
+func proxyHints() []string {
+	result := []string{}
+	for _, key := range proxyVariables {
+		if len(os.Getenv(key)) > 0 {
+			result = append(result, key)
+		}
+	}
+	if len(settings.Global.HttpsProxy()) > 0 {
+		result = append(result, "settings https-proxy")
+	}
+	if len(settings.Global.HttpProxy()) > 0 {
+		result = append(result, "settings http-proxy")
+	}
+	return result
+}
+
+func hostWithPort(link *url.URL) string {
+	port := link.Port()
+	if len(port) == 0 {
+		port = "443"
+		if link.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(link.Hostname(), port)
+}
+
+func proxyTunnel(transport *http.Transport, proxy *url.URL, target string, timeout time.Duration) (err error) {
+	defer fail.Around(&err)
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var connection net.Conn
+	if proxy.Scheme == "https" {
+		connection, err = tls.DialWithDialer(dialer, "tcp", hostWithPort(proxy), transport.TLSClientConfig)
+	} else {
+		connection, err = dialer.Dial("tcp", hostWithPort(proxy))
+	}
+	fail.On(err != nil, "Could not connect proxy, reason: %w", err)
+	defer connection.Close()
+	connection.SetDeadline(time.Now().Add(timeout))
+
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := fmt.Sprintf("%s:%s", proxy.User.Username(), password)
+		request += fmt.Sprintf("Proxy-Authorization: Basic %s\r\n", base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	_, err = connection.Write([]byte(request + "\r\n"))
+	fail.On(err != nil, "Could not send CONNECT request, reason: %w", err)
+	response, err := http.ReadResponse(bufio.NewReader(connection), &http.Request{Method: "CONNECT"})
+	fail.On(err != nil, "Could not read CONNECT response, reason: %w", err)
+	defer response.Body.Close()
+	fail.On(response.StatusCode == http.StatusProxyAuthRequired, "Proxy requires authentication: %s", response.Status)
+	fail.On(response.StatusCode != http.StatusOK, "Proxy refused tunnel: %s", response.Status)
+	return nil
+}
+
+func proxyCheck(timeout time.Duration) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	transport := settings.Global.ConfiguredHttpTransport()
+	canary, err := url.Parse(settings.Global.DownloadsLink(""))
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyTunnel,
+			Code:     common.CodeProxyCanaryInvalid,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not parse canary host %q, reason: %v", settings.Global.DownloadsLink(""), err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	proxy, err := proxyFor(transport, canary.Hostname())
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyTunnel,
+			Code:     common.CodeProxyUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not resolve proxy for %q, reason: %v", canary.Hostname(), err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if proxy == nil {
+		hints := proxyHints()
+		if len(hints) > 0 {
+			return &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkProxyTunnel,
+				Code:     common.CodeProxyHintsIgnored,
+				Status:   statusWarning,
+				Message:  fmt.Sprintf("No proxy is in effect for %q, but there are proxy hints: %s. Check your proxy and NO_PROXY configuration.", canary.Hostname(), strings.Join(hints, ", ")),
+				Link:     supportNetworkUrl,
+			}
+		}
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyTunnel,
+			Code:     common.CodeProxyNotNeeded,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("No proxy is configured or needed for %q.", canary.Hostname()),
+			Link:     supportNetworkUrl,
+		}
+	}
+	target := hostWithPort(canary)
+	err = proxyTunnel(transport, proxy, target, timeout)
+	if err != nil {
+		status := statusWarning
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			status = statusFail
+		}
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkProxyTunnel,
+			Code:     common.CodeProxyTunnelFailed,
+			Status:   status,
+			Message:  fmt.Sprintf("Tunnel to %q via proxy %s failed: %v", target, proxy.Redacted(), err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkProxyTunnel,
+		Code:     common.CodeProxyTunnelOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Tunnel to %q via proxy %s was established.", target, proxy.Redacted()),
+		Link:     supportNetworkUrl,
+	}
+}
@@ -78,7 +78,7 @@ func downloadMissingEnvironmentParts(count int, origin, catalogName, selection s
 	body := strings.NewReader(selection)
 	filename = filepath.Join(pathlib.TempDir(), fmt.Sprintf("rccremote_%x.zip", os.Getpid()))
 
-	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport()}
+	client := &http.Client{Transport: settings.Global.SharedHttpTransport()}
 	request, err := http.NewRequest("POST", url, body)
 	fail.On(err != nil, "Failed create request to %q failed, reason: %v", url, err)
 
@@ -0,0 +1,167 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/conda"
+	"github.com/robocorp/rcc/pathlib"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	toolVersionTimeout = 5 * time.Second
+)
+
+func realPath(location string) string {
+	resolved, err := filepath.EvalSymlinks(location)
+	if err != nil {
+		return filepath.Clean(location)
+	}
+	return resolved
+}
+
+func rccExecutablesOnPath() []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, directory := range pathlib.TargetPath() {
+		found, ok := pathlib.PathFrom(directory).Which("rcc", conda.FileExtensions)
+		if !ok {
+			continue
+		}
+		real := realPath(found)
+		if seen[real] {
+			continue
+		}
+		seen[real] = true
+		result = append(result, found)
+	}
+	return result
+}
+
+// toolVersion runs executable with deadline, and kills it if it does not
+// finish in time, since any binary found from PATH may hang
+func toolVersion(executable string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, executable, args...)
+	command.WaitDelay = time.Second
+	output, err := command.Output()
+	if ctx.Err() != nil {
+		return "timeout"
+	}
+	if err != nil {
+		return "unknown"
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)
+	return strings.TrimSpace(lines[0])
+}
+
+func rccOnPathCheck() *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	self := common.BinRcc()
+	selfReal := realPath(self)
+	found := rccExecutablesOnPath()
+	others := []string{}
+	for _, candidate := range found {
+		if realPath(candidate) != selfReal {
+			others = append(others, fmt.Sprintf("%s [%s]", candidate, toolVersion(candidate, "version")))
+		}
+	}
+	if len(others) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryRccOnPath,
+			Code:     common.CodeRccOnPathOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("No other rcc executables than running %q found on PATH.", self),
+			Link:     supportGeneralUrl,
+		}
+	}
+	listing := strings.Join(others, ", ")
+	if realPath(found[0]) != selfReal {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryRccOnPath,
+			Code:     common.CodeRccShadowed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Running rcc is %q [%s], but %q would be resolved first from PATH. Other rcc executables on PATH: %s", self, common.Version, found[0], listing),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryRccOnPath,
+		Code:     common.CodeRccFirstOnPath,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Running rcc %q is resolved first from PATH. Other rcc executables on PATH: %s", self, listing),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func temporaryDirectories() []string {
+	candidates := []string{os.TempDir(), os.Getenv("TMPDIR"), os.Getenv("TEMP"), os.Getenv("TMP")}
+	if !conda.IsWindows() {
+		candidates = append(candidates, "/tmp", "/var/tmp")
+	}
+	result := []string{}
+	for _, candidate := range candidates {
+		if len(candidate) > 0 {
+			result = append(result, realPath(candidate))
+		}
+	}
+	return result
+}
+
+func insideAny(location string, directories []string) (string, bool) {
+	for _, directory := range directories {
+		relative, err := filepath.Rel(directory, location)
+		if err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return directory, true
+		}
+	}
+	return "", false
+}
+
+// rccLocationCheck warns when running rcc is in temporary directory (where
+// it may vanish) or is quarantined (which makes Gatekeeper interfere)
+func rccLocationCheck(self string, temporary []string, quarantine bool) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	real := realPath(self)
+	if quarantine {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryRccLocation,
+			Code:     common.CodeRccQuarantined,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Running rcc %q has com.apple.quarantine attribute, so Gatekeeper may block or slow it down. Move it to permanent location and remove attribute.", self),
+			Link:     supportGeneralUrl,
+			Command:  fmt.Sprintf("xattr -d com.apple.quarantine %q", real),
+		}
+	}
+	directory, ok := insideAny(real, temporary)
+	if ok {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryRccLocation,
+			Code:     common.CodeRccInTemp,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Running rcc %q is inside temporary directory %q, where it may be cleaned away or lack permissions. Move it to permanent location (like ~/bin or /usr/local/bin) and put that on PATH.", self, directory),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryRccLocation,
+		Code:     common.CodeRccLocationOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Running rcc %q is in permanent location.", self),
+		Link:     supportGeneralUrl,
+	}
+}
@@ -0,0 +1,51 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestRccLocationCheckWarnsAboutTempAndQuarantine(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	temporary := filepath.Join(t.TempDir(), "tmp")
+	permanent := filepath.Join(t.TempDir(), "bin")
+	temps := []string{temporary}
+
+	check := rccLocationCheck(filepath.Join(permanent, "rcc"), temps, false)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal(uint64(common.CategoryRccLocation), check.Category)
+
+	check = rccLocationCheck(filepath.Join(temporary, "download", "rcc"), temps, false)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, "temporary directory"))
+
+	must_be.Equal(statusOk, rccLocationCheck(temporary+"-other/rcc", temps, false).Status)
+
+	check = rccLocationCheck(filepath.Join(permanent, "rcc"), temps, true)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Command, "xattr -d com.apple.quarantine"))
+}
+
+func TestToolVersionKillsHangingTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell script as tool")
+	}
+	must_be, _ := hamlet.Specifications(t)
+
+	tool := filepath.Join(t.TempDir(), "tool")
+	must_be.Nil(os.WriteFile(tool, []byte("#!/bin/sh\necho tool 1.0\nexec sleep 60\n"), 0o755))
+	defer func(original time.Duration) { toolVersionTimeout = original }(toolVersionTimeout)
+	toolVersionTimeout = 200 * time.Millisecond
+
+	started := time.Now()
+	must_be.Equal("timeout", toolVersion(tool, "--version"))
+	must_be.True(time.Since(started) < 10*time.Second)
+}
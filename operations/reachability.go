@@ -0,0 +1,116 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	maxRedirects = 5
+)
+
+// sameSite approximates registrable domain comparison by last two labels,
+// which is good enough to accept redirects like "robocorp.com" to
+// "www.robocorp.com", but not redirects to some other site
+func sameSite(one, other string) bool {
+	site := func(host string) string {
+		labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+		if len(labels) > 2 {
+			labels = labels[len(labels)-2:]
+		}
+		return strings.Join(labels, ".")
+	}
+	return site(one) == site(other)
+}
+
+// redirectChain follows redirects (up to limit) and returns all visited
+// URLs, starting from link itself, with final status code
+func redirectChain(client *http.Client, link string, limit int) (chain []string, status int, err error) {
+	chain = []string{link}
+	follower := *client
+	follower.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		chain = append(chain, request.URL.String())
+		if len(via) >= limit {
+			return fmt.Errorf("stopped after %d redirects", limit)
+		}
+		return nil
+	}
+	request, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return chain, 0, err
+	}
+	request.Header.Add("User-Agent", common.UserAgent())
+	response, err := follower.Do(request)
+	if err != nil {
+		return chain, 0, err
+	}
+	defer response.Body.Close()
+	return chain, response.StatusCode, nil
+}
+
+func reachabilityCheck(host string, chain []string, status int, err error) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	route := strings.Join(chain, " -> ")
+	if err != nil {
+		var failure *url.Error
+		if errors.As(err, &failure) {
+			err = failure.Err
+		}
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkReachability,
+			Code:     common.CodeReachabilityFailed,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("HTTP GET of %q failed: %v [route: %s]", host, err, route),
+			Link:     supportNetworkUrl,
+		}
+	}
+	offsite := []string{}
+	for _, visited := range chain[1:] {
+		target, err := url.Parse(visited)
+		if err == nil && !sameSite(host, target.Hostname()) {
+			offsite = append(offsite, target.Hostname())
+		}
+	}
+	if len(offsite) > 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkReachability,
+			Code:     common.CodeReachabilityRedirected,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("HTTP GET of %q was redirected to other host(s) %s, which may be interception or login portal; final status %d [route: %s]", host, strings.Join(offsite, ", "), status, route),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if status > 499 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkReachability,
+			Code:     common.CodeReachabilityServerError,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("HTTP GET of %q ended with server error status %d [route: %s]", host, status, route),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkReachability,
+		Code:     common.CodeReachabilityOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("HTTP GET of %q reached final status %d [route: %s]", host, status, route),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func httpReachabilityCheck(host string, timeout time.Duration) *common.DiagnosticCheck {
+	client := &http.Client{Transport: settings.Global.ConfiguredHttpTransport(), Timeout: timeout}
+	chain, status, err := redirectChain(client, fmt.Sprintf("https://%s/", host), maxRedirects)
+	return reachabilityCheck(host, chain, status, err)
+}
@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestReachabilityReportsRedirectChain(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer elsewhere.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/":
+			http.Redirect(writer, request, "/login", http.StatusFound)
+		case "/login":
+			http.Redirect(writer, request, elsewhere.URL+"/portal", http.StatusFound)
+		default:
+			http.Redirect(writer, request, request.URL.Path, http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	chain, status, err := redirectChain(server.Client(), server.URL+"/", maxRedirects)
+	must_be.Nil(err)
+	must_be.Equal(http.StatusOK, status)
+	must_be.Equal(3, len(chain))
+	must_be.Equal(elsewhere.URL+"/portal", chain[2])
+
+	_, _, err = redirectChain(server.Client(), server.URL+"/loop", maxRedirects)
+	wont_be.Nil(err)
+
+	must_be.True(sameSite("robocorp.com", "www.robocorp.com"))
+	wont_be.True(sameSite("robocorp.com", "portal.example.com"))
+
+	check := reachabilityCheck("api.robocorp.com", []string{"https://api.robocorp.com/", "https://downloads.robocorp.com/"}, 200, nil)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal(uint64(common.CategoryNetworkReachability), check.Category)
+	check = reachabilityCheck("api.robocorp.com", []string{"https://api.robocorp.com/", "https://login.example.com/"}, 200, nil)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, "login.example.com"))
+	check = reachabilityCheck("api.robocorp.com", []string{"https://api.robocorp.com/"}, 503, nil)
+	must_be.Equal(statusWarning, check.Status)
+}
@@ -0,0 +1,171 @@
+package operations
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/set"
+	"github.com/robocorp/rcc/settings"
+)
+
+func subjectName(raw []byte) string {
+	var sequence pkix.RDNSequence
+	_, err := asn1.Unmarshal(raw, &sequence)
+	if err != nil {
+		return fmt.Sprintf("[unparsable subject: %v]", err)
+	}
+	var name pkix.Name
+	name.FillFromRDNSequence(&sequence)
+	return name.String()
+}
+
+func rootCaSubjects(pool *x509.CertPool) []string {
+	if pool == nil {
+		return []string{}
+	}
+	// system pool roots are not visible on platforms which use native
+	// verifier (windows, macos), so this may be incomplete there
+	raw := pool.Subjects()
+	result := make([]string, 0, len(raw))
+	for _, subject := range raw {
+		result = append(result, subjectName(subject))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func bundleCertificates(filename string) ([]*x509.Certificate, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	result := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, certificate)
+	}
+	return result, nil
+}
+
+func missingFromPool(pool *x509.CertPool, certificates []*x509.Certificate) []string {
+	known := make(map[string]bool)
+	if pool != nil {
+		for _, subject := range pool.Subjects() {
+			known[string(subject)] = true
+		}
+	}
+	result := []string{}
+	for _, certificate := range certificates {
+		if !known[string(certificate.RawSubject)] {
+			result = append(result, certificate.Subject.String())
+		}
+	}
+	return result
+}
+
+func tlsRootStoreCheck(details map[string]string) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	pool := settings.Global.ConfiguredHttpTransport().TLSClientConfig.RootCAs
+	source := "configured root pool"
+	if pool == nil {
+		pool, _ = x509.SystemCertPool()
+		source = "system certificate store"
+	}
+	subjects := rootCaSubjects(pool)
+	details["tls-root-ca-count"] = fmt.Sprintf("%d", len(subjects))
+	if common.DebugFlag() {
+		details["tls-root-ca-subjects"] = strings.Join(subjects, "; ")
+	}
+	if !settings.Global.HasCaBundle() {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSRoots,
+			Code:     common.CodeTlsRootsOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("TLS verification uses %d visible root CAs from %s, and no custom CA bundle.", len(subjects), source),
+			Link:     supportNetworkUrl,
+		}
+	}
+	bundle := common.CaBundleFile()
+	certificates, err := bundleCertificates(bundle)
+	if err != nil || len(certificates) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSRoots,
+			Code:     common.CodeTlsRootsBundleEmpty,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Custom CA bundle %q has no usable certificates (%v), so TLS verification uses only %d visible root CAs from %s.", bundle, err, len(subjects), source),
+			Link:     supportNetworkUrl,
+		}
+	}
+	missing := missingFromPool(pool, certificates)
+	if len(missing) > 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSRoots,
+			Code:     common.CodeTlsRootsBundleIgnored,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Custom CA bundle %q certificates are not in effect for TLS verification: %s", bundle, strings.Join(missing, "; ")),
+			Link:     supportNetworkUrl,
+		}
+	}
+	names := make([]string, 0, len(certificates))
+	for _, certificate := range certificates {
+		names = append(names, certificate.Subject.String())
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSRoots,
+		Code:     common.CodeTlsRootsBundleOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("TLS verification uses %d visible root CAs from %s, including %d from custom CA bundle %q: %s", len(subjects), source, len(certificates), bundle, strings.Join(names, "; ")),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func tlsIssuersCheck(roots map[string]bool) *common.DiagnosticCheck {
+	if len(roots) == 0 {
+		return nil
+	}
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	issuers := make([]string, 0, len(roots))
+	failed := 0
+	for _, issuer := range set.Keys(roots) {
+		state := "verified"
+		if !roots[issuer] {
+			state = "NOT verified"
+			failed += 1
+		}
+		issuers = append(issuers, fmt.Sprintf("%q %s", issuer, state))
+	}
+	status, code := statusOk, uint64(common.CodeTlsIssuersOk)
+	if failed > 0 {
+		status, code = statusWarning, common.CodeTlsIssuersUnverified
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSIssuers,
+		Code:     code,
+		Status:   status,
+		Message:  fmt.Sprintf("Observed %d TLS chain issuers, %d not verified by trusted roots: %s", len(roots), failed, strings.Join(issuers, ", ")),
+		Link:     supportNetworkUrl,
+	}
+}
@@ -0,0 +1,39 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+func holotreeSpacesCheck(blueprints map[string]int, details map[string]string) *common.DiagnosticCheck {
+	spaces := 0
+	hashes := make([]string, 0, len(blueprints))
+	for blueprint, count := range blueprints {
+		details[fmt.Sprintf("holotree-blueprint:%s", blueprint)] = fmt.Sprintf("%d", count)
+		hashes = append(hashes, blueprint)
+		spaces += count
+	}
+	details["holotree-spaces"] = fmt.Sprintf("%d", spaces)
+	details["holotree-blueprints"] = fmt.Sprintf("%d", len(blueprints))
+	message := fmt.Sprintf("%d holotree spaces are materialized from %d distinct blueprints.", spaces, len(blueprints))
+	if spaces > len(blueprints) {
+		sort.Slice(hashes, func(left, right int) bool {
+			if blueprints[hashes[left]] == blueprints[hashes[right]] {
+				return hashes[left] < hashes[right]
+			}
+			return blueprints[hashes[left]] > blueprints[hashes[right]]
+		})
+		message = fmt.Sprintf("%s Most reused blueprint is %q with %d spaces.", message, hashes[0], blueprints[hashes[0]])
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryHolotreeSpaces,
+		Code:     common.CodeHolotreeSpaces,
+		Status:   statusOk,
+		Message:  message,
+		Link:     settings.Global.DocsLink("troubleshooting"),
+	}
+}
@@ -0,0 +1,25 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestHolotreeSpacesAreCountedByBlueprint(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	details := make(map[string]string)
+	check := holotreeSpacesCheck(map[string]int{"aaa": 1, "bbb": 3, "ccc": 3}, details)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal("7", details["holotree-spaces"])
+	must_be.Equal("3", details["holotree-blueprints"])
+	must_be.Equal("3", details["holotree-blueprint:bbb"])
+	must_be.True(strings.Contains(check.Message, `"bbb" with 3 spaces`))
+
+	details = make(map[string]string)
+	check = holotreeSpacesCheck(map[string]int{}, details)
+	must_be.Equal("0", details["holotree-spaces"])
+	must_be.True(strings.HasPrefix(check.Message, "0 holotree spaces"))
+}
@@ -0,0 +1,107 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/fail"
+	"github.com/robocorp/rcc/settings"
+)
+
+func execProbe(directory string) (err error) {
+	defer fail.Around(&err)
+
+	folder, err := os.MkdirTemp(directory, ".rcc_exec_probe")
+	fail.On(err != nil, "Could not create directory under %q, reason: %v", directory, err)
+	defer os.RemoveAll(folder)
+
+	script := filepath.Join(folder, execProbeName)
+	err = os.WriteFile(script, []byte(execProbeScript), 0o755)
+	fail.On(err != nil, "Could not write %q, reason: %v", script, err)
+	err = exec.Command(script).Run()
+	fail.On(err != nil, "Could not execute %q, reason: %v", script, err)
+	return nil
+}
+
+func tempDirectoryCheck(label, directory, status string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	err := os.MkdirAll(directory, 0o755)
+	if err == nil {
+		err = execProbe(directory)
+	}
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryTempDirectory,
+			Code:     common.CodeTempDirectoryUnusable,
+			Status:   status,
+			Message:  fmt.Sprintf("%s %q is not writable and executable: %v", label, directory, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryTempDirectory,
+		Code:     common.CodeTempDirectoryOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("%s %q is writable and executable.", label, directory),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func robocorpHomeExecCheck(details map[string]string) *common.DiagnosticCheck {
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	home := common.RobocorpHome()
+	flags := mountFlags(home)
+	if len(flags) > 0 {
+		details["robocorp-home-mount-flags"] = flags
+	}
+	err := execProbe(home)
+	if err != nil {
+		if len(flags) > 0 {
+			err = fmt.Errorf("%v [mount flags: %s]", err, flags)
+		}
+		return &common.DiagnosticCheck{
+			Type:     "RPA",
+			Category: common.CategoryRobocorpHomeExec,
+			Code:     common.CodeHomeExecBlocked,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Cannot write and execute programs in ROBOCORP_HOME %q (noexec mount or permissions?), so Python from holotree environments cannot run: %v", home, err),
+			Link:     supportGeneralUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "RPA",
+		Category: common.CategoryRobocorpHomeExec,
+		Code:     common.CodeHomeExecOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("ROBOCORP_HOME %q allows writing and executing programs.", home),
+		Link:     supportGeneralUrl,
+	}
+}
+
+func effectiveTempDirectory() string {
+	if common.DisableTempManagement() {
+		return os.TempDir()
+	}
+	return common.RobocorpTempRoot()
+}
+
+func tempDirectoryChecks(details map[string]string) []*common.DiagnosticCheck {
+	system := os.TempDir()
+	if common.DisableTempManagement() {
+		details["tempdir-effective"] = system
+		return []*common.DiagnosticCheck{
+			tempDirectoryCheck("Effective temp directory (temp management disabled, so system one)", system, statusFail),
+		}
+	}
+	effective := effectiveTempDirectory()
+	details["tempdir-effective"] = effective
+	return []*common.DiagnosticCheck{
+		tempDirectoryCheck("Effective rcc temp directory (used as TEMP/TMP for robots)", effective, statusFail),
+		tempDirectoryCheck("System temp directory", system, statusWarning),
+	}
+}
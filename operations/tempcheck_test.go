@@ -0,0 +1,21 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestExecProbeCleansUpAfterItself(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	directory := t.TempDir()
+	must_be.Nil(execProbe(directory))
+	entries, err := os.ReadDir(directory)
+	must_be.Nil(err)
+	must_be.Equal(0, len(entries))
+
+	wont_be.Nil(execProbe(filepath.Join(directory, "missing")))
+}
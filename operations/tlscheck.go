@@ -1,18 +1,23 @@
 package operations
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"hash"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,8 +39,9 @@ type (
 )
 
 var (
-	tlsVersions   = map[uint16]string{}
-	knownVersions = []uint16{
+	weakCipherMarkers = []string{"_CBC_", "TLS_RSA_", "_RC4_", "_3DES_"}
+	tlsVersions       = map[uint16]string{}
+	knownVersions     = []uint16{
 		tls.VersionTLS13,
 		tls.VersionTLS12,
 		tls.VersionTLS11,
@@ -52,10 +58,97 @@ func init() {
 	tlsVersions[tls.VersionTLS13] = "TLS 1.3"
 }
 
-func tlsCheckHeadOnly(url string) (*tls.ConnectionState, error) {
+// clientAuthProbe presents configured client certificate, and records
+// if server asked for one during handshake
+type clientAuthProbe struct {
+	certificate *tls.Certificate
+	requested   bool
+	presented   bool
+}
+
+// newClientAuthProbe uses same client certificate, which configured transport
+// presents, and only reloads it to explain why there is none
+func newClientAuthProbe() (*clientAuthProbe, error) {
+	config := settings.Global.ConfiguredHttpTransport().TLSClientConfig
+	if len(config.Certificates) > 0 {
+		return &clientAuthProbe{certificate: &config.Certificates[0]}, nil
+	}
+	_, err := settings.Global.ClientCertificate()
+	return &clientAuthProbe{}, err
+}
+
+func (it *clientAuthProbe) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	it.requested = true
+	if it.certificate == nil {
+		return &tls.Certificate{}, nil
+	}
+	it.presented = true
+	return it.certificate, nil
+}
+
+// tlsRoute overrides where TLS checks connect to, and which SNI name they
+// present, for testing servers behind reverse proxies and load balancers
+type tlsRoute struct {
+	connect string
+	sni     string
+}
+
+func (it *tlsRoute) apply(transport *http.Transport) {
+	if it == nil {
+		return
+	}
+	if len(it.sni) > 0 {
+		transport.TLSClientConfig.ServerName = it.sni
+	}
+	if len(it.connect) == 0 {
+		return
+	}
+	// direct connection, since proxy would be the one connecting
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, it.address(port))
+	}
+}
+
+func (it *tlsRoute) address(port string) string {
+	_, _, err := net.SplitHostPort(it.connect)
+	if err == nil {
+		return it.connect
+	}
+	return net.JoinHostPort(it.connect, port)
+}
+
+func (it *tlsRoute) String() string {
+	if it == nil {
+		return ""
+	}
+	parts := []string{}
+	if len(it.connect) > 0 {
+		parts = append(parts, fmt.Sprintf("connect %s", it.connect))
+	}
+	if len(it.sni) > 0 {
+		parts = append(parts, fmt.Sprintf("SNI %s", it.sni))
+	}
+	return fmt.Sprintf(" [%s]", strings.Join(parts, ", "))
+}
+
+func tlsCheckHeadOnly(url string, probe *clientAuthProbe) (*tls.ConnectionState, error) {
+	return tlsCheckHeadOnlyVia(url, nil, probe)
+}
+
+func tlsCheckHeadOnlyVia(url string, route *tlsRoute, probe *clientAuthProbe) (*tls.ConnectionState, error) {
 	transport := settings.Global.ConfiguredHttpTransport()
+	route.apply(transport)
 	transport.TLSClientConfig.InsecureSkipVerify = true
 	transport.TLSClientConfig.MinVersion = tls.VersionSSL30
+	if probe != nil {
+		transport.TLSClientConfig.GetClientCertificate = probe.clientCertificate
+	}
 	// above two setting are needed for TLS checks
 	// they weaken security, and that is why this code is only used
 	// to get TLS connection state and nothing else
@@ -85,45 +178,204 @@ func certificateChain(certificates []*x509.Certificate) string {
 	return strings.Join(parts, "; ")
 }
 
+// chainOrder puts certificates in issuing order starting from leaf, since
+// servers (and intercepting proxies) do not always send them in order
+func chainOrder(certificates []*x509.Certificate) []*x509.Certificate {
+	if len(certificates) == 0 {
+		return certificates
+	}
+	used := make([]bool, len(certificates))
+	used[0] = true
+	result := []*x509.Certificate{certificates[0]}
+	current := certificates[0]
+search:
+	for {
+		for at, candidate := range certificates {
+			if !used[at] && bytes.Equal(current.RawIssuer, candidate.RawSubject) {
+				used[at] = true
+				result = append(result, candidate)
+				current = candidate
+				continue search
+			}
+		}
+		break
+	}
+	for at, certificate := range certificates {
+		if !used[at] {
+			result = append(result, certificate)
+		}
+	}
+	return result
+}
+
+func ExportCertificateChain(host string, w io.Writer) (err error) {
+	defer fail.Around(&err)
+
+	url := fmt.Sprintf("https://%s/", host)
+	probe, _ := newClientAuthProbe()
+	state, err := tlsCheckHeadOnly(url, probe)
+	fail.On(err != nil, "Could not connect %q, reason: %v", url, err)
+	fail.On(state == nil || len(state.PeerCertificates) == 0, "No TLS certificates seen from %q.", url)
+	for at, certificate := range chainOrder(state.PeerCertificates) {
+		fmt.Fprintf(w, "# Host: %q, chain #%d (rcc %s)\n", host, at, common.Version)
+		fmt.Fprintln(w, "# Issuer:", certificate.Issuer)
+		fmt.Fprintln(w, "# Subject:", certificate.Subject)
+		fmt.Fprintf(w, "# SHA256 Fingerprint: %s\n", sha256Fingerprint(certificate))
+		err = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+		fail.On(err != nil, "Could not PEM encode certificate, reason: %v", err)
+	}
+	return nil
+}
+
+func tlsVersionCheck(host string, negotiated, required uint16, policy bool) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	version := tlsVersions[negotiated]
+	if negotiated >= required {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSVersion,
+			Code:     common.CodeTlsVersionOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("TLS version: %q -> %s", host, version),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if policy {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSVersion,
+			Code:     common.CodeTlsVersionBelowPolicy,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("TLS version: %q -> %s, but policy requires at least %s.", host, version, tlsVersions[required]),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSVersion,
+		Code:     common.CodeTlsVersionOld,
+		Status:   statusWarning,
+		Message:  fmt.Sprintf("TLS version: %q -> %s", host, version),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func weakCipherSuite(suite uint16) bool {
+	for _, insecure := range tls.InsecureCipherSuites() {
+		if insecure.ID == suite {
+			return true
+		}
+	}
+	name := tls.CipherSuiteName(suite)
+	for _, marker := range weakCipherMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func tlsCipherCheck(host string, suite uint16) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	name := tls.CipherSuiteName(suite)
+	if weakCipherSuite(suite) {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSCipher,
+			Code:     common.CodeTlsCipherWeak,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("TLS cipher suite: %q -> %s, which is considered weak (CBC mode, RSA key exchange, or otherwise insecure).", host, name),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSCipher,
+		Code:     common.CodeTlsCipherOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("TLS cipher suite: %q -> %s", host, name),
+		Link:     supportNetworkUrl,
+	}
+}
+
+// tlsProtocolCheck is informational report of ALPN negotiated protocol,
+// which tells if HTTP/2 is really used end to end (or if something, like
+// intercepting proxy, downgrades connection to HTTP/1.1)
+func tlsProtocolCheck(host, negotiated string) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	protocol := "HTTP/1.1 (no ALPN)"
+	switch negotiated {
+	case "h2":
+		protocol = "HTTP/2"
+	case "http/1.1":
+		protocol = "HTTP/1.1"
+	case "":
+	default:
+		protocol = negotiated
+	}
+	message := fmt.Sprintf("HTTP protocol: %q -> %s", host, protocol)
+	if negotiated != "h2" {
+		message = fmt.Sprintf("%s, so HTTP/2 is not in use (server or proxy did not negotiate it).", message)
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkALPN,
+		Code:     common.CodeTlsAlpn,
+		Status:   statusOk,
+		Message:  message,
+		Link:     supportNetworkUrl,
+	}
+}
+
 func tlsCheckHost(host string, roots map[string]bool) []*common.DiagnosticCheck {
+	return tlsCheckHostVia(host, roots, nil)
+}
+
+func tlsCheckHostVia(host string, roots map[string]bool, route *tlsRoute) []*common.DiagnosticCheck {
 	transport := settings.Global.ConfiguredHttpTransport()
 	result := []*common.DiagnosticCheck{}
 	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
 	url := fmt.Sprintf("https://%s/", host)
-	state, err := tlsCheckHeadOnly(url)
+	probe, err := newClientAuthProbe()
+	if err != nil {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSClientAuth,
+			Code:     common.CodeClientCertInvalid,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("%v [TLS check of %q continues without client certificate]", err, host),
+			Link:     supportNetworkUrl,
+		})
+	}
+	state, err := tlsCheckHeadOnlyVia(url, route, probe)
+	result = append(result, just(tlsClientAuthCheck(host, probe, err))...)
 	if err != nil {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkLink,
+			Code:     common.CodeNetworkConnectFailed,
 			Status:   statusWarning,
-			Message:  fmt.Sprintf("%s -> %v", url, err),
+			Message:  fmt.Sprintf("%s -> %v%s", url, err, route),
 			Link:     supportNetworkUrl,
 		})
 		return result
 	}
 	server := state.ServerName
-	version, ok := tlsVersions[state.Version]
+	_, ok := tlsVersions[state.Version]
 	if !ok {
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkTLSVersion,
+			Code:     common.CodeTlsVersionUnknown,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("unknown TLS version: %q -> %03x", host, state.Version),
 			Link:     supportNetworkUrl,
 		})
 	} else {
-		tlsStatus := statusOk
-		if state.Version < tls.VersionTLS12 {
-			tlsStatus = statusWarning
-		}
-		result = append(result, &common.DiagnosticCheck{
-			Type:     "network",
-			Category: common.CategoryNetworkTLSVersion,
-			Status:   tlsStatus,
-			Message:  fmt.Sprintf("TLS version: %q -> %s", host, version),
-			Link:     supportNetworkUrl,
-		})
+		result = append(result, tlsVersionCheck(host, state.Version, settings.Global.MinTLSVersion(), settings.Global.HasTLSPolicy()))
 	}
+	result = append(result, tlsCipherCheck(host, state.CipherSuite))
+	result = append(result, tlsProtocolCheck(host, state.NegotiatedProtocol))
 	toVerify := x509.VerifyOptions{
 		DNSName:       server,
 		Roots:         transport.TLSClientConfig.RootCAs,
@@ -134,6 +386,7 @@ func tlsCheckHost(host string, roots map[string]bool) []*common.DiagnosticCheck
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkTLSVerify,
+			Code:     common.CodeTlsNoCertificates,
 			Status:   statusWarning,
 			Message:  fmt.Sprintf("no certificates for %s", server),
 			Link:     supportNetworkUrl,
@@ -151,14 +404,16 @@ func tlsCheckHost(host string, roots map[string]bool) []*common.DiagnosticCheck
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkTLSVerify,
+			Code:     common.CodeTlsVerifyFailed,
 			Status:   statusWarning,
-			Message:  fmt.Sprintf("TLS verification of %q failed, reason: %v [last issuer: %q]", server, err, last.Issuer),
+			Message:  fmt.Sprintf("TLS verification of %q failed, reason: %v [last issuer: %q]%s", server, err, last.Issuer, route),
 			Link:     supportNetworkUrl,
 		})
 		if common.DebugFlag() {
 			result = append(result, &common.DiagnosticCheck{
 				Type:     "network",
 				Category: common.CategoryNetworkTLSChain,
+				Code:     common.CodeTlsChain,
 				Status:   statusWarning,
 				Message:  fmt.Sprintf("%q certificate chain is {%s}.", host, certificateChain(certificates)),
 				Link:     supportNetworkUrl,
@@ -168,14 +423,239 @@ func tlsCheckHost(host string, roots map[string]bool) []*common.DiagnosticCheck
 		result = append(result, &common.DiagnosticCheck{
 			Type:     "network",
 			Category: common.CategoryNetworkTLSVerify,
+			Code:     common.CodeTlsVerifyOk,
 			Status:   statusOk,
-			Message:  fmt.Sprintf("TLS verification of %q passed with certificate issued by %q", server, last.Issuer),
+			Message:  fmt.Sprintf("TLS verification of %q passed with certificate issued by %q%s", server, last.Issuer, route),
 			Link:     supportNetworkUrl,
 		})
 	}
+	result = append(result, just(tlsPinCheck(host, certificates[0], settings.Global.PinnedKeys(host)))...)
+	result = append(result, tlsExpiryCheck(server, certificates[0], time.Now(), settings.Global.CertificateExpiryDays()))
+	return append(result, tlsOcspCheck(server, state.OCSPResponse, certificates[0], ocspIssuer(state)))
+}
+
+func tlsClientAuthCheck(host string, probe *clientAuthProbe, err error) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	switch {
+	case probe.requested && !probe.presented:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSClientAuth,
+			Code:     common.CodeClientCertMissing,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Server %q requested TLS client certificate, but none is configured [certificates/client-certificate in settings.yaml].", host),
+			Link:     supportNetworkUrl,
+		}
+	case probe.presented && err != nil:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSClientAuth,
+			Code:     common.CodeClientCertRejected,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Server %q did not accept configured TLS client certificate, reason: %v", host, err),
+			Link:     supportNetworkUrl,
+		}
+	case probe.presented:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSClientAuth,
+			Code:     common.CodeClientCertAccepted,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Server %q accepted configured TLS client certificate.", host),
+			Link:     supportNetworkUrl,
+		}
+	case probe.certificate != nil && err == nil:
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSClientAuth,
+			Code:     common.CodeClientCertNotRequested,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Server %q did not request TLS client certificate.", host),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return nil
+}
+
+func spkiPin(certificate *x509.Certificate) string {
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+func tlsPinCheck(host string, leaf *x509.Certificate, pins []string) *common.DiagnosticCheck {
+	if len(pins) == 0 {
+		return nil
+	}
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	actual := spkiPin(leaf)
+	for _, pin := range pins {
+		if pin == actual {
+			return &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkTLSPin,
+				Code:     common.CodeTlsPinOk,
+				Status:   statusOk,
+				Message:  fmt.Sprintf("TLS public key of %q matches pinned key %q.", host, actual),
+				Link:     supportNetworkUrl,
+			}
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSPin,
+		Code:     common.CodeTlsPinMismatch,
+		Status:   statusFail,
+		Message:  fmt.Sprintf("TLS public key of %q is %q, which does not match any of %d pinned keys [issuer: %q]. Connection may be intercepted!", host, actual, len(pins), leaf.Issuer),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func tlsExpiryCheck(server string, leaf *x509.Certificate, now time.Time, limit int) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	expires := leaf.NotAfter.Format("2006-Jan-02")
+	days := int(leaf.NotAfter.Sub(now).Hours() / 24)
+	if now.After(leaf.NotAfter) {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSExpiry,
+			Code:     common.CodeTlsExpired,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("TLS certificate of %q has expired %d days ago [%s].", server, -days, expires),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if days < limit {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSExpiry,
+			Code:     common.CodeTlsExpiring,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("TLS certificate of %q expires in %d days [%s], which is less than %d days.", server, days, expires, limit),
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSExpiry,
+		Code:     common.CodeTlsExpiryOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("TLS certificate of %q is valid for %d more days [%s].", server, days, expires),
+		Link:     supportNetworkUrl,
+	}
+}
+
+func normalizeTlsHost(host string) (string, error) {
+	candidate := strings.ToLower(strings.TrimSpace(host))
+	if len(candidate) == 0 || strings.ContainsAny(candidate, "/@?# ") {
+		return "", fmt.Errorf("%q is not bare hostname or host:port", host)
+	}
+	name, port, err := net.SplitHostPort(candidate)
+	if err != nil {
+		name, port = candidate, ""
+	}
+	name = strings.TrimSuffix(strings.Trim(name, "[]"), ".")
+	if len(name) == 0 || strings.Contains(name, ":") && net.ParseIP(name) == nil {
+		return "", fmt.Errorf("%q is not bare hostname or host:port", host)
+	}
+	if len(port) > 0 {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return "", fmt.Errorf("%q has invalid port %q", host, port)
+		}
+	}
+	if len(port) == 0 || port == "443" {
+		if strings.Contains(name, ":") {
+			return fmt.Sprintf("[%s]", name), nil
+		}
+		return name, nil
+	}
+	return net.JoinHostPort(name, port), nil
+}
+
+// CheckTLS runs TLS host checks against given hosts, for example when
+// verifying new on-prem endpoint instead of configured diagnostics hosts.
+func CheckTLS(hosts []string) []*common.DiagnosticCheck {
+	return checkTLSVia(hosts, nil)
+}
+
+func checkTLSVia(hosts []string, route *tlsRoute) []*common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	result := []*common.DiagnosticCheck{}
+	roots := make(map[string]bool)
+	for _, host := range hosts {
+		normalized, err := normalizeTlsHost(host)
+		if err != nil {
+			result = append(result, &common.DiagnosticCheck{
+				Type:     "network",
+				Category: common.CategoryNetworkLink,
+				Code:     common.CodeNetworkTlsHostInvalid,
+				Status:   statusFail,
+				Message:  fmt.Sprintf("Cannot check TLS: %v", err),
+				Link:     supportNetworkUrl,
+			})
+			continue
+		}
+		result = append(result, tlsCheckHostVia(normalized, roots, route)...)
+	}
 	return result
 }
 
+func tlsMinimumCheck() *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	url := settings.Global.CanaryURL()
+	transport := settings.Global.ConfiguredHttpTransport()
+	transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+	client := http.Client{
+		Transport: transport,
+		Timeout:   5 * time.Second,
+	}
+	response, err := client.Head(url)
+	var dialError *net.OpError
+	if err != nil && errors.As(err, &dialError) && dialError.Op == "dial" {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSMinimum,
+			Code:     common.CodeTlsMinimumUnknown,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Could not connect %s, so TLS 1.2+ capability was not verified: %v", url, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSMinimum,
+			Code:     common.CodeTlsMinimumFailed,
+			Status:   statusFail,
+			Message:  fmt.Sprintf("Could not negotiate TLS 1.2 or newer with %s, which rcc requires: %v", url, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	defer response.Body.Close()
+	if response.TLS == nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkTLSMinimum,
+			Code:     common.CodeTlsMinimumNoTls,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Connection to %s did not use TLS at all.", url),
+			Link:     supportNetworkUrl,
+		}
+	}
+	version, ok := tlsVersions[response.TLS.Version]
+	if !ok {
+		version = fmt.Sprintf("%03x", response.TLS.Version)
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkTLSMinimum,
+		Code:     common.CodeTlsMinimumOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Host can negotiate required TLS 1.2+ with %s, negotiated %s.", url, version),
+		Link:     supportNetworkUrl,
+	}
+}
+
 func configurationVariations(root *x509.CertPool) tlsConfigs {
 	configs := make(tlsConfigs, len(knownVersions))
 	for at, version := range knownVersions {
@@ -381,9 +861,10 @@ func pickVerifiedCertificates(roots *x509.CertPool, candidates []*x509.Certifica
 func tlsExportUrls(roots *x509.CertPool, unique map[string]bool, urls []string, untrusted bool) (ok bool, err error) {
 	defer fail.Around(&err)
 	ok = true
+	probe, _ := newClientAuthProbe()
 search:
 	for _, url := range urls {
-		state, err := tlsCheckHeadOnly(url)
+		state, err := tlsCheckHeadOnly(url, probe)
 		if err != nil {
 			ok = false
 			pretty.Warning("Failed to check URL %q for TLS certificates, reason: %v", url, err)
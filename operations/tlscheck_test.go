@@ -0,0 +1,357 @@
+package operations
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestCertificateExpiryFollowsLimit(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	now := time.Now()
+	leaf := func(days int) *x509.Certificate {
+		return &x509.Certificate{NotAfter: now.Add(time.Duration(days) * 24 * time.Hour)}
+	}
+	must_be.Equal(statusOk, tlsExpiryCheck("example.com", leaf(90), now, 30).Status)
+	must_be.Equal(statusWarning, tlsExpiryCheck("example.com", leaf(10), now, 30).Status)
+	must_be.Equal(statusOk, tlsExpiryCheck("example.com", leaf(10), now, 7).Status)
+	must_be.Equal(statusFail, tlsExpiryCheck("example.com", leaf(-2), now, 30).Status)
+}
+
+func TestChainOrderFollowsIssuers(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	certificate := func(subject, issuer string) *x509.Certificate {
+		return &x509.Certificate{RawSubject: []byte(subject), RawIssuer: []byte(issuer)}
+	}
+	leaf := certificate("leaf", "middle")
+	middle := certificate("middle", "root")
+	root := certificate("root", "root")
+	stray := certificate("stray", "elsewhere")
+
+	must_be.Equal(0, len(chainOrder([]*x509.Certificate{})))
+	ordered := chainOrder([]*x509.Certificate{leaf, stray, root, middle})
+	must_be.Equal(4, len(ordered))
+	must_be.Equal(leaf, ordered[0])
+	must_be.Equal(middle, ordered[1])
+	must_be.Equal(root, ordered[2])
+	must_be.Equal(stray, ordered[3])
+}
+
+func TestCanExportObservedCertificateChain(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sink := &strings.Builder{}
+	must_be.Nil(ExportCertificateChain(strings.TrimPrefix(server.URL, "https://"), sink))
+	block, _ := pem.Decode([]byte(sink.String()))
+	wont_be.Nil(block)
+	must_be.Equal("CERTIFICATE", block.Type)
+	must_be.Equal(server.Certificate().Raw, block.Bytes)
+}
+
+func ocspIssuerKey(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, issuer
+}
+
+func stapledOcsp(t *testing.T, key *ecdsa.PrivateKey, single ocspSingleResponse) []byte {
+	tbs, err := asn1.Marshal(ocspResponseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: []byte{4, 0}},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []ocspSingleResponse{single},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := asn1.Marshal(ocspBasic{
+		TBSResponseData:    ocspResponseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := asn1.Marshal(ocspResponse{Response: ocspResponseBytes{ResponseType: ocspBasicResponse, Response: basic}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestCanReportStapledOcspStatus(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	key, issuer := ocspIssuerKey(t)
+	stranger, _ := ocspIssuerKey(t)
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(42)}
+	now := time.Now().UTC().Truncate(time.Second)
+	certID := ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+		NameHash:      []byte{1},
+		IssuerKeyHash: []byte{2},
+		SerialNumber:  big.NewInt(42),
+	}
+
+	must_be.Equal(statusOk, tlsOcspCheck("example.com", nil, leaf, issuer).Status)
+	must_be.Equal(statusWarning, tlsOcspCheck("example.com", []byte{1, 2, 3}, leaf, issuer).Status)
+
+	good := tlsOcspCheck("example.com", stapledOcsp(t, key, ocspSingleResponse{CertID: certID, Good: true, ThisUpdate: now, NextUpdate: now.Add(time.Hour)}), leaf, issuer)
+	must_be.Equal(statusOk, good.Status)
+	must_be.True(strings.Contains(good.Message, "good"))
+	must_be.True(strings.Contains(good.Message, "next update"))
+
+	revocation := ocspSingleResponse{CertID: certID, Revoked: ocspRevokedInfo{RevocationTime: now}, ThisUpdate: now}
+	must_be.Equal(statusFail, tlsOcspCheck("example.com", stapledOcsp(t, key, revocation), leaf, issuer).Status)
+	must_be.Equal(statusWarning, tlsOcspCheck("example.com", stapledOcsp(t, stranger, revocation), leaf, issuer).Status)
+	must_be.Equal(statusWarning, tlsOcspCheck("example.com", stapledOcsp(t, key, revocation), leaf, nil).Status)
+
+	other := &x509.Certificate{SerialNumber: big.NewInt(7)}
+	must_be.Equal(statusWarning, tlsOcspCheck("example.com", stapledOcsp(t, key, revocation), other, issuer).Status)
+
+	unknown := tlsOcspCheck("example.com", stapledOcsp(t, key, ocspSingleResponse{CertID: certID, Unknown: true, ThisUpdate: now}), leaf, issuer)
+	must_be.Equal(statusWarning, unknown.Status)
+}
+
+func TestCanNormalizeTlsHosts(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	valid := map[string]string{
+		"internal.example.com":      "internal.example.com",
+		" Internal.Example.COM. ":   "internal.example.com",
+		"internal.example.com:443":  "internal.example.com",
+		"internal.example.com:8443": "internal.example.com:8443",
+		"10.0.0.1:8443":             "10.0.0.1:8443",
+		"[::1]:8443":                "[::1]:8443",
+		"::1":                       "[::1]",
+	}
+	for host, expected := range valid {
+		normalized, err := normalizeTlsHost(host)
+		must_be.Nil(err)
+		must_be.Equal(expected, normalized)
+	}
+	for _, host := range []string{"", "https://internal.example.com", "internal.example.com/path", "user@host", "host:0", "host:https", "host:70000"} {
+		_, err := normalizeTlsHost(host)
+		wont_be.Nil(err)
+	}
+}
+
+func TestTlsVersionFollowsPolicy(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(statusOk, tlsVersionCheck("example.com", tls.VersionTLS13, tls.VersionTLS12, false).Status)
+	must_be.Equal(statusWarning, tlsVersionCheck("example.com", tls.VersionTLS11, tls.VersionTLS12, false).Status)
+	must_be.Equal(statusOk, tlsVersionCheck("example.com", tls.VersionTLS13, tls.VersionTLS13, true).Status)
+	failed := tlsVersionCheck("example.com", tls.VersionTLS12, tls.VersionTLS13, true)
+	must_be.Equal(statusFail, failed.Status)
+	must_be.True(strings.Contains(failed.Message, "TLS 1.2"))
+	must_be.True(strings.Contains(failed.Message, "TLS 1.3"))
+}
+
+func TestCanClassifyWeakCipherSuites(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	must_be.True(weakCipherSuite(tls.TLS_RSA_WITH_AES_128_GCM_SHA256))
+	must_be.True(weakCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA))
+	must_be.True(weakCipherSuite(tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA))
+	wont_be.True(weakCipherSuite(tls.TLS_AES_128_GCM_SHA256))
+	wont_be.True(weakCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384))
+	wont_be.True(weakCipherSuite(tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256))
+
+	must_be.Equal(statusWarning, tlsCipherCheck("example.com", tls.TLS_RSA_WITH_AES_256_CBC_SHA).Status)
+	must_be.Equal(statusOk, tlsCipherCheck("example.com", tls.TLS_AES_256_GCM_SHA384).Status)
+	must_be.True(strings.Contains(tlsCipherCheck("example.com", tls.TLS_AES_256_GCM_SHA384).Message, "TLS_AES_256_GCM_SHA384"))
+}
+
+func TestCanReportTlsClientAuthOutcome(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	clientAuthServer := func(policy tls.ClientAuthType) *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{ClientAuth: policy, ClientCAs: x509.NewCertPool()}
+		server.StartTLS()
+		return server
+	}
+
+	plain := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	probe := &clientAuthProbe{}
+	_, err := tlsCheckHeadOnly(plain.URL, probe)
+	must_be.Nil(err)
+	wont_be.True(probe.requested)
+	must_be.Nil(tlsClientAuthCheck("plain", probe, err))
+
+	anyCert := clientAuthServer(tls.RequireAnyClientCert)
+	defer anyCert.Close()
+	probe = &clientAuthProbe{}
+	_, err = tlsCheckHeadOnly(anyCert.URL, probe)
+	must_be.True(probe.requested)
+	must_be.Equal(statusWarning, tlsClientAuthCheck("any", probe, err).Status)
+
+	certificate := anyCert.TLS.Certificates[0]
+	probe = &clientAuthProbe{certificate: &certificate}
+	_, err = tlsCheckHeadOnly(anyCert.URL, probe)
+	must_be.Nil(err)
+	must_be.True(probe.presented)
+	must_be.Equal(statusOk, tlsClientAuthCheck("any", probe, err).Status)
+
+	verified := clientAuthServer(tls.RequireAndVerifyClientCert)
+	defer verified.Close()
+	probe = &clientAuthProbe{certificate: &certificate}
+	_, err = tlsCheckHeadOnly(verified.URL, probe)
+	wont_be.Nil(err)
+	must_be.Equal(statusFail, tlsClientAuthCheck("verified", probe, err).Status)
+}
+
+func TestCanSeeWhichBundleCertificatesAreInEffect(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca-bundle.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	must_be.Nil(os.WriteFile(bundle, content, 0o644))
+	certificates, err := bundleCertificates(bundle)
+	must_be.Nil(err)
+	must_be.Equal(1, len(certificates))
+
+	pool := x509.NewCertPool()
+	must_be.Equal(1, len(missingFromPool(pool, certificates)))
+	pool.AddCert(certificates[0])
+	must_be.Equal(0, len(missingFromPool(pool, certificates)))
+	subjects := rootCaSubjects(pool)
+	must_be.Equal(1, len(subjects))
+	must_be.Equal(certificates[0].Subject.String(), subjects[0])
+
+	_, err = bundleCertificates(filepath.Join(t.TempDir(), "missing.pem"))
+	wont_be.Nil(err)
+}
+
+func TestIssuersCheckListsUnverifiedIssuers(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Nil(tlsIssuersCheck(map[string]bool{}))
+	check := tlsIssuersCheck(map[string]bool{"CN=Good Root": true})
+	must_be.Equal(statusOk, check.Status)
+	check = tlsIssuersCheck(map[string]bool{"CN=Good Root": true, "CN=Proxy Root": false})
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, `"CN=Proxy Root" NOT verified`))
+	must_be.True(strings.Contains(check.Message, `"CN=Good Root" verified`))
+}
+
+func TestPinnedKeyMustMatchLeafPublicKey(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	leaf := server.Certificate()
+	pin := spkiPin(leaf)
+	must_be.Equal(44, len(pin))
+
+	must_be.Nil(tlsPinCheck("example.com", leaf, []string{}))
+	wont_be.Nil(tlsPinCheck("example.com", leaf, []string{pin}))
+	must_be.Equal(statusOk, tlsPinCheck("example.com", leaf, []string{"bogus", pin}).Status)
+	must_be.Equal(statusFail, tlsPinCheck("example.com", leaf, []string{"bogus"}).Status)
+}
+
+func TestTlsRouteOverridesConnectAndSni(t *testing.T) {
+	must_be, wont_be := hamlet.Specifications(t)
+
+	seen := ""
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			seen = hello.ServerName
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	var route *tlsRoute
+	must_be.Equal("", route.String())
+
+	address := strings.TrimPrefix(server.URL, "https://")
+	route = &tlsRoute{connect: address, sni: "api.example.com"}
+	must_be.Equal(" [connect "+address+", SNI api.example.com]", route.String())
+	must_be.Equal(address, route.address("443"))
+	must_be.Equal("10.0.0.5:443", (&tlsRoute{connect: "10.0.0.5"}).address("443"))
+
+	state, err := tlsCheckHeadOnlyVia("https://nowhere.invalid/", route, nil)
+	must_be.Nil(err)
+	wont_be.Nil(state)
+	must_be.Equal("api.example.com", seen)
+}
+
+func TestTlsProtocolReportsNegotiatedHttp2(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	state, err := tlsCheckHeadOnly(server.URL, nil)
+	must_be.Nil(err)
+	must_be.Equal("h2", state.NegotiatedProtocol)
+
+	check := tlsProtocolCheck("example.com", state.NegotiatedProtocol)
+	must_be.Equal(statusOk, check.Status)
+	must_be.Equal(uint64(common.CategoryNetworkALPN), check.Category)
+	must_be.Equal(`HTTP protocol: "example.com" -> HTTP/2`, check.Message)
+
+	check = tlsProtocolCheck("example.com", "http/1.1")
+	must_be.Equal(statusOk, check.Status)
+	must_be.True(strings.Contains(check.Message, "HTTP/2 is not in use"))
+}
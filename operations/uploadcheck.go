@@ -0,0 +1,97 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/robocorp/rcc/cloud"
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+const (
+	uploadProbeBody = `rcc upload probe`
+)
+
+func uploadProbe(client cloud.Client, resource, method string) *cloud.Response {
+	request := client.NewRequest(resource)
+	request.Headers["Content-Type"] = "text/plain"
+	request.Body = bytes.NewReader([]byte(uploadProbeBody))
+	request.ContentLength = int64(len(uploadProbeBody))
+	if method == http.MethodPut {
+		return client.Put(request)
+	}
+	return client.Post(request)
+}
+
+// uploadBlocked tells if write request was refused, compared to GET of same
+// resource; method filtering proxies answer like 403, 405, or 501 to writes
+func uploadBlocked(download, response *cloud.Response) bool {
+	if response.Err != nil || response.Status == http.StatusProxyAuthRequired {
+		return true
+	}
+	return response.Status >= 400 && response.Status != download.Status
+}
+
+func uploadCheck() *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	link := settings.Global.UploadURL()
+	if len(link) == 0 {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkUpload,
+			Code:     common.CodeUploadNotConfigured,
+			Status:   statusWarning,
+			Message:  "Upload check needs diagnostics/upload-url in settings.yaml (endpoint accepting POST and PUT), so outbound writes were not checked.",
+			Link:     supportNetworkUrl,
+		}
+	}
+	return uploadLinkCheck(link, 10*time.Second)
+}
+
+func uploadLinkCheck(link string, timeout time.Duration) *common.DiagnosticCheck {
+	supportNetworkUrl := settings.Global.DocsLink("troubleshooting/firewall-and-proxies")
+	endpoint, resource := splitLink(link)
+	client, err := cloud.NewClient(endpoint)
+	if err != nil {
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkUpload,
+			Code:     common.CodeUploadInvalidLink,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Upload check could not use %q, reason: %v", link, err),
+			Link:     supportNetworkUrl,
+		}
+	}
+	client = client.WithTimeout(timeout)
+	download := client.Get(client.NewRequest(resource))
+	downloadWorks := download.Err == nil && download.Status < 400
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		response := uploadProbe(client, resource, method)
+		if !uploadBlocked(download, response) {
+			continue
+		}
+		message := fmt.Sprintf("Outbound %s to %s is blocked [status %d: %v], while GET also fails [status %d]. Check network connectivity first.", method, link, response.Status, response.Err, download.Status)
+		if downloadWorks {
+			message = fmt.Sprintf("Outbound %s to %s is blocked [status %d: %v], while GET works [status %d]. Proxy or firewall seems to filter write methods, so uploads will fail.", method, link, response.Status, response.Err, download.Status)
+		}
+		return &common.DiagnosticCheck{
+			Type:     "network",
+			Category: common.CategoryNetworkUpload,
+			Code:     common.CodeUploadBlocked,
+			Status:   statusWarning,
+			Message:  message,
+			Link:     supportNetworkUrl,
+		}
+	}
+	return &common.DiagnosticCheck{
+		Type:     "network",
+		Category: common.CategoryNetworkUpload,
+		Code:     common.CodeUploadOk,
+		Status:   statusOk,
+		Message:  fmt.Sprintf("Outbound POST and PUT traffic to %s is permitted [server responded].", link),
+		Link:     supportNetworkUrl,
+	}
+}
@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestUploadCheckComparesWritesToGet(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	filtering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer filtering.Close()
+	check := uploadLinkCheck(filtering.URL+"/echo", time.Second)
+	must_be.Equal(statusWarning, check.Status)
+	must_be.True(strings.Contains(check.Message, "POST"))
+	must_be.True(strings.Contains(check.Message, "while GET works"))
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer echo.Close()
+	must_be.Equal(statusOk, uploadLinkCheck(echo.URL+"/echo", time.Second).Status)
+}
@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/settings"
+)
+
+var (
+	windowsDrivePattern = regexp.MustCompile(`^/mnt/[a-zA-Z](/|$)`)
+)
+
+// wslKernel is kernel version line when running under WSL, otherwise empty
+func wslKernel() string {
+	content, err := os.ReadFile("/proc/version")
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSpace(string(content))
+	if !strings.Contains(strings.ToLower(version), "microsoft") {
+		return ""
+	}
+	return version
+}
+
+func wslChecks(details map[string]string, version, home string) []*common.DiagnosticCheck {
+	if len(version) == 0 {
+		return nil
+	}
+	supportGeneralUrl := settings.Global.DocsLink("troubleshooting")
+	details["wsl-kernel"] = version
+	result := []*common.DiagnosticCheck{}
+	home = filepath.ToSlash(filepath.Clean(home))
+	if windowsDrivePattern.MatchString(home) {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryWsl,
+			Code:     common.CodeWslWindowsMount,
+			Status:   statusWarning,
+			Message:  fmt.Sprintf("Running under WSL with ROBOCORP_HOME (%s) on Windows drive mount, which makes environment building and file access slow. Use ROBOCORP_HOME inside WSL filesystem, like ~/.robocorp instead.", home),
+			Link:     supportGeneralUrl,
+		})
+	} else {
+		result = append(result, &common.DiagnosticCheck{
+			Type:     "OS",
+			Category: common.CategoryWsl,
+			Code:     common.CodeWslOk,
+			Status:   statusOk,
+			Message:  fmt.Sprintf("Running under WSL with ROBOCORP_HOME (%s) inside WSL filesystem.", home),
+			Link:     supportGeneralUrl,
+		})
+	}
+	return append(result, &common.DiagnosticCheck{
+		Type:     "OS",
+		Category: common.CategoryWsl,
+		Code:     common.CodeWslClockDrift,
+		Status:   statusOk,
+		Message:  "Note: WSL clock may drift after host sleep or hibernate, which breaks TLS and authentication. If clock skew is reported, run: sudo hwclock -s (or wsl --shutdown on Windows side).",
+		Link:     supportGeneralUrl,
+	})
+}
@@ -0,0 +1,29 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robocorp/rcc/common"
+	"github.com/robocorp/rcc/hamlet"
+)
+
+func TestWslChecksWarnAboutWindowsDriveHome(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	details := make(map[string]string)
+	must_be.Equal(0, len(wslChecks(details, "", "/mnt/c/Users/me/robocorp")))
+	must_be.Equal("", details["wsl-kernel"])
+
+	version := "Linux version 5.15.133.1-microsoft-standard-WSL2"
+	checks := wslChecks(details, version, "/mnt/c/Users/me/robocorp")
+	must_be.Equal(2, len(checks))
+	must_be.Equal(statusWarning, checks[0].Status)
+	must_be.Equal(uint64(common.CategoryWsl), checks[0].Category)
+	must_be.Equal(version, details["wsl-kernel"])
+
+	checks = wslChecks(details, version, "/home/me/.robocorp")
+	must_be.Equal(statusOk, checks[0].Status)
+	must_be.True(strings.Contains(checks[1].Message, "clock"))
+	must_be.Equal(statusOk, wslChecks(details, version, "/mnt/data/robocorp")[0].Status)
+}
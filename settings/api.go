@@ -1,7 +1,9 @@
 package settings
 
 import (
+	"crypto/tls"
 	"net/http"
+	"time"
 
 	"github.com/robocorp/rcc/common"
 )
@@ -26,14 +28,32 @@ type Api interface {
 	PypiLink(page string) string
 	CondaLink(page string) string
 	Hostnames() []string
+	DiagnosticHosts() []string
+	DiagnosticsTimeout() time.Duration
+	CertificateExpiryDays() int
+	DiagnosticsPayloadSize() int64
+	DiagnosticsStallTimeout() time.Duration
+	DiagnosticsMinimumMbps() float64
+	SuppressedChecks() []string
+	CanaryURL() string
+	CanaryContent() string
+	CanaryHelpLink() string
+	UploadURL() string
 	ConfiguredHttpTransport() *http.Transport
+	SharedHttpTransport() *http.Transport
 	NoProxy() string
 	HttpsProxy() string
 	HttpProxy() string
+	IpFamily() string
+	IpNetwork() string
 	HasPipRc() bool
 	HasMicroMambaRc() bool
 	HasCaBundle() bool
 	VerifySsl() bool
+	HasTLSPolicy() bool
+	MinTLSVersion() uint16
+	ClientCertificate() (*tls.Certificate, error)
+	PinnedKeys(host string) []string
 	NoRevocation() bool
 	LegacyRenegotiation() bool
 	NoBuid() bool
@@ -2,6 +2,7 @@ package settings
 
 import (
 	"encoding/json"
+	"net"
 	"net/url"
 	"sort"
 	"strings"
@@ -33,6 +34,7 @@ func (it SettingsLayers) Effective() *Settings {
 		Branding:     make(StringMap),
 		Certificates: &Certificates{},
 		Network:      &Network{},
+		Diagnosing:   &Diagnosing{},
 		Endpoints:    make(StringMap),
 		Options:      make(BoolMap),
 		Hosts:        make([]string, 0, 100),
@@ -56,6 +58,7 @@ type Settings struct {
 	Branding     StringMap     `yaml:"branding,omitempty" json:"branding,omitempty"`
 	Certificates *Certificates `yaml:"certificates,omitempty" json:"certificates,omitempty"`
 	Network      *Network      `yaml:"network,omitempty" json:"network,omitempty"`
+	Diagnosing   *Diagnosing   `yaml:"diagnostics,omitempty" json:"diagnostics,omitempty"`
 	Endpoints    StringMap     `yaml:"endpoints,omitempty" json:"endpoints,omitempty"`
 	Hosts        []string      `yaml:"diagnostics-hosts,omitempty" json:"diagnostics-hosts,omitempty"`
 	Options      BoolMap       `yaml:"options,omitempty" json:"options,omitempty"`
@@ -110,6 +113,9 @@ func (it *Settings) onTopOf(target *Settings) {
 	if it.Network != nil {
 		it.Network.onTopOf(target)
 	}
+	if it.Diagnosing != nil {
+		it.Diagnosing.onTopOf(target)
+	}
 	if it.Meta != nil {
 		it.Meta.onTopOf(target)
 	}
@@ -130,6 +136,34 @@ func (it *Settings) Source(filename string) *Settings {
 	return it
 }
 
+func sortedHostnames(collector map[string]bool) []string {
+	result := make([]string, 0, len(collector))
+	for key, _ := range collector {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (it *Settings) diagnosticHosts(collector map[string]bool) {
+	for _, name := range it.Hosts {
+		name = strings.TrimSpace(name)
+		if strings.Contains(name, "://") {
+			hostFromUrl(name, collector)
+			continue
+		}
+		if len(name) > 0 {
+			collector[name] = true
+		}
+	}
+}
+
+func (it *Settings) DiagnosticHosts() []string {
+	collector := make(map[string]bool)
+	it.diagnosticHosts(collector)
+	return sortedHostnames(collector)
+}
+
 func (it *Settings) Hostnames() []string {
 	collector := make(map[string]bool)
 	if it.Endpoints != nil {
@@ -137,16 +171,48 @@ func (it *Settings) Hostnames() []string {
 			hostFromUrl(name, collector)
 		}
 	}
-	if it.Hosts != nil {
-		for _, name := range it.Hosts {
-			collector[name] = true
-		}
+	it.diagnosticHosts(collector)
+	return sortedHostnames(collector)
+}
+
+// DocsLink resolves page against "docs" endpoint, so that profiles can
+// point troubleshooting links to internal documentation portal.
+func (it *Settings) DocsLink(page string) string {
+	docs := ""
+	if it.Endpoints != nil {
+		docs = strings.TrimSpace(it.Endpoints["docs"])
 	}
-	result := make([]string, 0, len(collector))
-	for key, _ := range collector {
-		result = append(result, key)
+	if len(docs) == 0 {
+		docs = docsDefault
+	}
+	if !strings.HasSuffix(docs, "/") {
+		docs += "/"
+	}
+	return resolveLink(docs, page)
+}
+
+// PinnedKeys returns expected base64 SHA256 hashes of leaf certificate
+// public key (SPKI) for host, or nothing when host is not pinned. Port
+// is not part of pinned name, so "host:port" is looked up as "host".
+func (it *Settings) PinnedKeys(host string) []string {
+	result := []string{}
+	if it.Certificates == nil {
+		return result
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	for name, pins := range it.Certificates.PinnedKeys {
+		if !strings.EqualFold(strings.TrimSpace(name), host) {
+			continue
+		}
+		for _, pin := range pins {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			if len(pin) > 0 {
+				result = append(result, pin)
+			}
+		}
 	}
-	sort.Strings(result)
 	return result
 }
 
@@ -225,16 +291,24 @@ func (it *Settings) Diagnostics(target *common.DiagnosticStatus) {
 		diagnose.Warning(0, "", "settings.yaml: meta section is totally missing")
 		correct = false
 	}
+	if it.Network != nil && !knownIpFamily(it.Network.IpFamily) {
+		diagnose.Warning(0, "", "settings.yaml: network/ip-family %q is not one of: %s", it.Network.IpFamily, strings.Join(ipFamilies, ", "))
+		correct = false
+	}
 	if correct {
 		diagnose.Ok(0, "In general, 'settings.yaml' is ok.")
 	}
 }
 
 type Certificates struct {
-	VerifySsl           bool   `yaml:"verify-ssl" json:"verify-ssl"`
-	SslNoRevoke         bool   `yaml:"ssl-no-revoke" json:"ssl-no-revoke"`
-	LegacyRenegotiation bool   `yaml:"legacy-renegotiation-allowed" json:"legacy-renegotiation-allowed"`
-	CaBundle            string `yaml:"ca-bundle,omitempty" json:"ca-bundle,omitempty"`
+	VerifySsl           bool                `yaml:"verify-ssl" json:"verify-ssl"`
+	SslNoRevoke         bool                `yaml:"ssl-no-revoke" json:"ssl-no-revoke"`
+	LegacyRenegotiation bool                `yaml:"legacy-renegotiation-allowed" json:"legacy-renegotiation-allowed"`
+	CaBundle            string              `yaml:"ca-bundle,omitempty" json:"ca-bundle,omitempty"`
+	MinTlsVersion       string              `yaml:"min-tls-version,omitempty" json:"min-tls-version,omitempty"`
+	ClientCertificate   string              `yaml:"client-certificate,omitempty" json:"client-certificate,omitempty"`
+	ClientKey           string              `yaml:"client-key,omitempty" json:"client-key,omitempty"`
+	PinnedKeys          map[string][]string `yaml:"pinned-keys,omitempty" json:"pinned-keys,omitempty"`
 }
 
 func (it *Certificates) onTopOf(target *Settings) {
@@ -247,6 +321,16 @@ func (it *Certificates) onTopOf(target *Settings) {
 	if pathlib.IsFile(common.CaBundleFile()) {
 		target.Certificates.CaBundle = common.CaBundleFile()
 	}
+	if len(it.MinTlsVersion) > 0 {
+		target.Certificates.MinTlsVersion = it.MinTlsVersion
+	}
+	if len(it.ClientCertificate) > 0 {
+		target.Certificates.ClientCertificate = it.ClientCertificate
+		target.Certificates.ClientKey = it.ClientKey
+	}
+	if len(it.PinnedKeys) > 0 {
+		target.Certificates.PinnedKeys = it.PinnedKeys
+	}
 }
 
 func justHostAndPort(link string) string {
@@ -297,6 +381,15 @@ type Network struct {
 	NoProxy    string `yaml:"no-proxy" json:"no-proxy"`
 	HttpsProxy string `yaml:"https-proxy" json:"https-proxy"`
 	HttpProxy  string `yaml:"http-proxy" json:"http-proxy"`
+	// MaxIdleConns limits idle (keep-alive) connections over all hosts.
+	MaxIdleConns int `yaml:"max-idle-connections,omitempty" json:"max-idle-connections,omitempty"`
+	// MaxIdlePerHost limits idle connections kept for reuse per host.
+	MaxIdlePerHost int `yaml:"max-idle-connections-per-host,omitempty" json:"max-idle-connections-per-host,omitempty"`
+	// IdleTimeoutSeconds is how long idle connection is kept before closing.
+	IdleTimeoutSeconds int `yaml:"idle-timeout-seconds,omitempty" json:"idle-timeout-seconds,omitempty"`
+	// IpFamily forces (ipv4, ipv6) or prefers (prefer-ipv4, prefer-ipv6)
+	// IP family of outgoing connections and diagnostics DNS lookups.
+	IpFamily string `yaml:"ip-family,omitempty" json:"ip-family,omitempty"`
 }
 
 func (it *Network) onTopOf(target *Settings) {
@@ -312,4 +405,64 @@ func (it *Network) onTopOf(target *Settings) {
 	if len(it.HttpProxy) > 0 {
 		target.Network.HttpProxy = it.HttpProxy
 	}
+	if it.MaxIdleConns > 0 {
+		target.Network.MaxIdleConns = it.MaxIdleConns
+	}
+	if it.MaxIdlePerHost > 0 {
+		target.Network.MaxIdlePerHost = it.MaxIdlePerHost
+	}
+	if it.IdleTimeoutSeconds > 0 {
+		target.Network.IdleTimeoutSeconds = it.IdleTimeoutSeconds
+	}
+	if len(it.IpFamily) > 0 {
+		target.Network.IpFamily = it.IpFamily
+	}
+}
+
+type Diagnosing struct {
+	TimeoutSeconds        int    `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+	CertificateExpiryDays int    `yaml:"certificate-expiry-days,omitempty" json:"certificate-expiry-days,omitempty"`
+	PayloadMegabytes      int    `yaml:"payload-megabytes,omitempty" json:"payload-megabytes,omitempty"`
+	StallSeconds          int    `yaml:"stall-seconds,omitempty" json:"stall-seconds,omitempty"`
+	MinimumMbps           int    `yaml:"minimum-mbps,omitempty" json:"minimum-mbps,omitempty"`
+	CanaryUrl             string `yaml:"canary-url,omitempty" json:"canary-url,omitempty"`
+	CanaryContent         string `yaml:"canary-content,omitempty" json:"canary-content,omitempty"`
+	CanaryHelp            string `yaml:"canary-help,omitempty" json:"canary-help,omitempty"`
+	UploadUrl             string `yaml:"upload-url,omitempty" json:"upload-url,omitempty"`
+	// selectors of checks, whose problems are accepted by policy
+	Suppress []string `yaml:"suppress-checks,omitempty" json:"suppress-checks,omitempty"`
+}
+
+func (it *Diagnosing) onTopOf(target *Settings) {
+	if target.Diagnosing == nil {
+		target.Diagnosing = &Diagnosing{}
+	}
+	if it.TimeoutSeconds > 0 {
+		target.Diagnosing.TimeoutSeconds = it.TimeoutSeconds
+	}
+	if it.CertificateExpiryDays > 0 {
+		target.Diagnosing.CertificateExpiryDays = it.CertificateExpiryDays
+	}
+	if it.PayloadMegabytes > 0 {
+		target.Diagnosing.PayloadMegabytes = it.PayloadMegabytes
+	}
+	if it.StallSeconds > 0 {
+		target.Diagnosing.StallSeconds = it.StallSeconds
+	}
+	if it.MinimumMbps > 0 {
+		target.Diagnosing.MinimumMbps = it.MinimumMbps
+	}
+	if len(it.CanaryUrl) > 0 {
+		target.Diagnosing.CanaryUrl = it.CanaryUrl
+	}
+	if len(it.CanaryContent) > 0 {
+		target.Diagnosing.CanaryContent = it.CanaryContent
+	}
+	if len(it.CanaryHelp) > 0 {
+		target.Diagnosing.CanaryHelp = it.CanaryHelp
+	}
+	if len(it.UploadUrl) > 0 {
+		target.Diagnosing.UploadUrl = it.UploadUrl
+	}
+	target.Diagnosing.Suppress = append(target.Diagnosing.Suppress, it.Suppress...)
 }
@@ -1,13 +1,17 @@
 package settings
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/robocorp/rcc/blobs"
 	"github.com/robocorp/rcc/common"
@@ -16,11 +20,34 @@ import (
 )
 
 const (
-	pypiDefault  = "https://pypi.org/simple/"
-	condaDefault = "https://conda.anaconda.org/"
+	pypiDefault               = "https://pypi.org/simple/"
+	condaDefault              = "https://conda.anaconda.org/"
+	docsDefault               = "https://robocorp.com/docs/"
+	canaryDefault             = "/canary.txt"
+	canaryContentDefault      = "Used to testing connections"
+	diagnosticsTimeoutDefault = 10 * time.Second
+	certificateExpiryDefault  = 30
+	payloadMegabytesDefault   = 4
+	stallTimeoutDefault       = 15 * time.Second
+	minimumMbpsDefault        = 2.0
+	// stdlib keeps only 2 idle connections per host, which is too few when
+	// same few hosts get many sequential and parallel requests
+	maxIdleConnsDefault   = 100
+	maxIdlePerHostDefault = 16
+	idleTimeoutDefault    = 90 * time.Second
+	dialTimeoutDefault    = 30 * time.Second
+	keepAliveDefault      = 30 * time.Second
 )
 
 var (
+	ipFamilies = []string{"ipv4", "ipv6", "prefer-ipv4", "prefer-ipv6"}
+
+	tlsPolicyVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
 	httpTransport  *http.Transport
 	cachedSettings *Settings
 	Global         gateway
@@ -186,6 +213,27 @@ func (it gateway) HttpsProxy() string {
 func (it gateway) HttpProxy() string {
 	return it.settings().Network.HttpProxy
 }
+
+// IpFamily is IP family forced or preferred by --ip-family flag or by
+// network/ip-family setting, or empty when both families are fine
+func (it gateway) IpFamily() string {
+	family := common.IpFamily
+	if len(family) == 0 {
+		family = it.settings().Network.IpFamily
+	}
+	family = strings.ToLower(strings.TrimSpace(family))
+	if !knownIpFamily(family) {
+		return ""
+	}
+	return family
+}
+
+// IpNetwork is resolver network ("ip", "ip4", or "ip6") matching forced
+// IP family; preferred family still resolves both
+func (it gateway) IpNetwork() string {
+	networks := familyNetworks(it.IpFamily(), "ip")
+	return networks[len(networks)-1]
+}
 func (it gateway) HasPipRc() bool {
 	return pathlib.IsFile(common.PipRcFile())
 }
@@ -203,7 +251,7 @@ func (it gateway) DownloadsLink(resource string) string {
 }
 
 func (it gateway) DocsLink(page string) string {
-	return resolveLink(it.Endpoint("docs"), page)
+	return it.settings().DocsLink(page)
 }
 
 func (it gateway) PypiLink(page string) string {
@@ -225,6 +273,148 @@ func (it gateway) CondaLink(page string) string {
 func (it gateway) Hostnames() []string {
 	return it.settings().Hostnames()
 }
+
+func (it gateway) DiagnosticHosts() []string {
+	return it.settings().DiagnosticHosts()
+}
+
+func (it gateway) DiagnosticsTimeout() time.Duration {
+	config := it.settings().Diagnosing
+	if config == nil || config.TimeoutSeconds < 1 {
+		return diagnosticsTimeoutDefault
+	}
+	return time.Duration(config.TimeoutSeconds) * time.Second
+}
+func (it gateway) CertificateExpiryDays() int {
+	config := it.settings().Diagnosing
+	if config == nil || config.CertificateExpiryDays < 1 {
+		return certificateExpiryDefault
+	}
+	return config.CertificateExpiryDays
+}
+
+func (it gateway) DiagnosticsPayloadSize() int64 {
+	config := it.settings().Diagnosing
+	if config == nil || config.PayloadMegabytes < 1 {
+		return payloadMegabytesDefault * 1024 * 1024
+	}
+	return int64(config.PayloadMegabytes) * 1024 * 1024
+}
+
+func (it gateway) DiagnosticsStallTimeout() time.Duration {
+	config := it.settings().Diagnosing
+	if config == nil || config.StallSeconds < 1 {
+		return stallTimeoutDefault
+	}
+	return time.Duration(config.StallSeconds) * time.Second
+}
+
+// DiagnosticsMinimumMbps is download throughput, below which diagnostics
+// warns about slow network
+func (it gateway) DiagnosticsMinimumMbps() float64 {
+	config := it.settings().Diagnosing
+	if config == nil || config.MinimumMbps < 1 {
+		return minimumMbpsDefault
+	}
+	return float64(config.MinimumMbps)
+}
+
+// SuppressedChecks are check selectors, whose non-ok results diagnostics
+// reports as ok, since problems behind them are accepted by policy.
+func (it gateway) SuppressedChecks() []string {
+	config := it.settings().Diagnosing
+	if config == nil {
+		return []string{}
+	}
+	return config.Suppress
+}
+
+// CanaryURL is full URL of canary file, which defaults to one on downloads
+// endpoint, so that mirrors can provide their own equivalent.
+func (it gateway) CanaryURL() string {
+	config := it.settings().Diagnosing
+	if config == nil || len(config.CanaryUrl) == 0 {
+		return it.DownloadsLink(canaryDefault)
+	}
+	return config.CanaryUrl
+}
+
+func (it gateway) CanaryContent() string {
+	config := it.settings().Diagnosing
+	if config == nil || len(config.CanaryContent) == 0 {
+		return canaryContentDefault
+	}
+	return config.CanaryContent
+}
+
+func (it gateway) CanaryHelpLink() string {
+	config := it.settings().Diagnosing
+	if config == nil || len(config.CanaryHelp) == 0 {
+		return it.DocsLink("troubleshooting/firewall-and-proxies")
+	}
+	return config.CanaryHelp
+}
+
+// UploadURL is endpoint, which accepts (and echoes or ignores) POST and PUT
+// requests for upload check; there is no default, so that diagnostics does
+// not write into production hosts.
+func (it gateway) UploadURL() string {
+	config := it.settings().Diagnosing
+	if config == nil {
+		return ""
+	}
+	return config.UploadUrl
+}
+
+func parseTlsVersion(text string) (uint16, bool) {
+	version, ok := tlsPolicyVersions[strings.TrimPrefix(strings.Replace(strings.ToLower(text), " ", "", -1), "tls")]
+	return version, ok
+}
+
+func (it gateway) HasTLSPolicy() bool {
+	config := it.settings().Certificates
+	if config == nil {
+		return false
+	}
+	_, ok := parseTlsVersion(config.MinTlsVersion)
+	return ok
+}
+
+func (it gateway) MinTLSVersion() uint16 {
+	config := it.settings().Certificates
+	if config == nil {
+		return tls.VersionTLS12
+	}
+	version, ok := parseTlsVersion(config.MinTlsVersion)
+	if !ok {
+		return tls.VersionTLS12
+	}
+	return version
+}
+
+// ClientCertificate loads configured client certificate (and key, which
+// defaults to same PEM file), or returns nil when none is configured.
+func (it gateway) ClientCertificate() (*tls.Certificate, error) {
+	config := it.settings().Certificates
+	if config == nil || len(config.ClientCertificate) == 0 {
+		return nil, nil
+	}
+	certfile := common.ExpandPath(config.ClientCertificate)
+	keyfile := certfile
+	if len(config.ClientKey) > 0 {
+		keyfile = common.ExpandPath(config.ClientKey)
+	}
+	certificate, err := tls.LoadX509KeyPair(certfile, keyfile)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load client certificate %q, reason: %v", certfile, err)
+	}
+	return &certificate, nil
+}
+
+func (it gateway) PinnedKeys(host string) []string {
+	return it.settings().PinnedKeys(host)
+}
+
 func (it gateway) VerifySsl() bool {
 	return it.settings().Certificates.VerifySsl
 }
@@ -242,10 +432,18 @@ func (it gateway) NoBuild() bool {
 	return nobuild || common.NoBuild || it.Option("no-build")
 }
 
+// ConfiguredHttpTransport is private copy of configured transport, for
+// callers which modify it; it has its own, initially empty connection pool.
 func (it gateway) ConfiguredHttpTransport() *http.Transport {
 	return httpTransport.Clone()
 }
 
+// SharedHttpTransport is configured transport itself, so that its tuned
+// connection pool is reused across clients; callers must not modify it.
+func (it gateway) SharedHttpTransport() *http.Transport {
+	return httpTransport
+}
+
 func (it gateway) loadRootCAs() *x509.CertPool {
 	roots, err := x509.SystemCertPool()
 	if err != nil {
@@ -313,4 +511,78 @@ func init() {
 		InsecureSkipVerify: !verifySsl,
 		RootCAs:            Global.loadRootCAs(),
 	}
+	certificate, problem := Global.ClientCertificate()
+	if problem != nil {
+		common.Log("Warning! %v", problem)
+	}
+	if certificate != nil {
+		httpTransport.TLSClientConfig.Certificates = []tls.Certificate{*certificate}
+	}
+	httpTransport.DialContext = familyDialer(&net.Dialer{
+		Timeout:   dialTimeoutDefault,
+		KeepAlive: keepAliveDefault,
+	})
+	if err == nil {
+		tuneConnectionPool(httpTransport, settings.Network)
+	} else {
+		tuneConnectionPool(httpTransport, nil)
+	}
+}
+
+func knownIpFamily(family string) bool {
+	if len(family) == 0 {
+		return true
+	}
+	for _, known := range ipFamilies {
+		if strings.EqualFold(family, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// familyNetworks lists networks to try, in order, when dialing network
+// (like "tcp") with given family forced or preferred
+func familyNetworks(family, network string) []string {
+	switch family {
+	case "ipv4":
+		return []string{network + "4"}
+	case "ipv6":
+		return []string{network + "6"}
+	case "prefer-ipv4":
+		return []string{network + "4", network}
+	case "prefer-ipv6":
+		return []string{network + "6", network}
+	}
+	return []string{network}
+}
+
+func familyDialer(dialer *net.Dialer) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (connection net.Conn, err error) {
+		for _, candidate := range familyNetworks(Global.IpFamily(), network) {
+			connection, err = dialer.DialContext(ctx, candidate, address)
+			if err == nil {
+				return connection, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+func tuneConnectionPool(transport *http.Transport, config *Network) {
+	if config == nil {
+		config = &Network{}
+	}
+	transport.MaxIdleConns = maxIdleConnsDefault
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+	transport.MaxIdleConnsPerHost = maxIdlePerHostDefault
+	if config.MaxIdlePerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdlePerHost
+	}
+	transport.IdleConnTimeout = idleTimeoutDefault
+	if config.IdleTimeoutSeconds > 0 {
+		transport.IdleConnTimeout = time.Duration(config.IdleTimeoutSeconds) * time.Second
+	}
 }
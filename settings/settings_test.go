@@ -1,8 +1,11 @@
 package settings_test
 
 import (
+	"crypto/tls"
 	"testing"
+	"time"
 
+	"github.com/robocorp/rcc/common"
 	"github.com/robocorp/rcc/hamlet"
 	"github.com/robocorp/rcc/settings"
 )
@@ -37,4 +40,98 @@ func TestThatSomeDefaultValuesAreVisible(t *testing.T) {
 	must_be.Equal("", settings.Global.HttpsProxy())
 	must_be.Equal("", settings.Global.NoProxy())
 	must_be.Equal(9, len(settings.Global.Hostnames()))
+	must_be.Equal(30, settings.Global.CertificateExpiryDays())
+	must_be.Equal(false, settings.Global.HasTLSPolicy())
+	must_be.Equal(uint16(tls.VersionTLS12), settings.Global.MinTLSVersion())
+	must_be.Equal(int64(4*1024*1024), settings.Global.DiagnosticsPayloadSize())
+	must_be.Equal(15*time.Second, settings.Global.DiagnosticsStallTimeout())
+	must_be.Equal(2.0, settings.Global.DiagnosticsMinimumMbps())
+	must_be.Equal("https://downloads.robocorp.com/canary.txt", settings.Global.CanaryURL())
+	must_be.Equal("Used to testing connections", settings.Global.CanaryContent())
+	must_be.Equal("https://robocorp.com/docs/troubleshooting/firewall-and-proxies", settings.Global.CanaryHelpLink())
+	transport := settings.Global.ConfiguredHttpTransport()
+	must_be.Equal(100, transport.MaxIdleConns)
+	must_be.Equal(16, transport.MaxIdleConnsPerHost)
+	must_be.Equal(90*time.Second, transport.IdleConnTimeout)
+	shared := settings.Global.SharedHttpTransport()
+	must_be.True(shared == settings.Global.SharedHttpTransport())
+	must_be.Equal(16, shared.MaxIdleConnsPerHost)
+	must_be.True(shared != transport)
+	certificate, err := settings.Global.ClientCertificate()
+	must_be.Nil(err)
+	must_be.Nil(certificate)
+}
+
+func TestDocsLinkCanPointToInternalPortal(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	sut := &settings.Settings{
+		Endpoints: settings.StringMap{"docs": "https://wiki.example.com/rpa/kb"},
+	}
+	must_be.Equal("https://wiki.example.com/rpa/kb/troubleshooting", sut.DocsLink("troubleshooting"))
+
+	sut.Endpoints["docs"] = " "
+	must_be.Equal("https://robocorp.com/docs/troubleshooting", sut.DocsLink("troubleshooting"))
+
+	sut = &settings.Settings{}
+	must_be.Equal("https://robocorp.com/docs/troubleshooting/firewall-and-proxies", sut.DocsLink("troubleshooting/firewall-and-proxies"))
+}
+
+func TestCanNormalizeDiagnosticHosts(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	sut := &settings.Settings{
+		Hosts: []string{" mirror.example.com ", "https://artifactory.example.com:8443/conda/", "mirror.example.com", ""},
+	}
+	hosts := sut.DiagnosticHosts()
+	must_be.Equal(2, len(hosts))
+	must_be.Equal("artifactory.example.com", hosts[0])
+	must_be.Equal("mirror.example.com", hosts[1])
+	must_be.Equal(hosts, sut.Hostnames())
+
+	must_be.True(len(settings.Global.DiagnosticHosts()) > 0)
+	must_be.True(len(settings.Global.DiagnosticHosts()) <= len(settings.Global.Hostnames()))
+}
+
+func TestPinnedKeysAreLookedUpByHost(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(0, len(settings.Global.PinnedKeys("api.eu1.robocorp.com")))
+
+	sut := &settings.Settings{
+		Certificates: &settings.Certificates{
+			PinnedKeys: map[string][]string{"API.example.com ": []string{"sha256/abc=", " ", "def="}},
+		},
+	}
+	must_be.Equal([]string{"abc=", "def="}, sut.PinnedKeys("api.example.com"))
+	must_be.Equal([]string{"abc=", "def="}, sut.PinnedKeys("api.example.com:443"))
+	must_be.Equal(0, len(sut.PinnedKeys("other.example.com:443")))
+	must_be.Equal(0, len((&settings.Settings{}).PinnedKeys("api.example.com")))
+}
+
+func TestIpFamilyFlagOverridesSetting(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	defer func() {
+		common.IpFamily = ""
+	}()
+	must_be.Equal("", settings.Global.IpFamily())
+	must_be.Equal("ip", settings.Global.IpNetwork())
+
+	common.IpFamily = "IPv4"
+	must_be.Equal("ipv4", settings.Global.IpFamily())
+	must_be.Equal("ip4", settings.Global.IpNetwork())
+
+	common.IpFamily = "prefer-ipv6"
+	must_be.Equal("prefer-ipv6", settings.Global.IpFamily())
+	must_be.Equal("ip", settings.Global.IpNetwork())
+
+	common.IpFamily = "ipv5"
+	must_be.Equal("", settings.Global.IpFamily())
+}
+
+func TestNoChecksAreSuppressedByDefault(t *testing.T) {
+	must_be, _ := hamlet.Specifications(t)
+
+	must_be.Equal(0, len(settings.Global.SuppressedChecks()))
 }